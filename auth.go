@@ -0,0 +1,221 @@
+package glhf
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Authorization header constant.
+	Authorization = "Authorization"
+	// WWWAuthenticate header constant.
+	WWWAuthenticate = "WWW-Authenticate"
+	// APIKeyHeader is the default header API keys are read from.
+	APIKeyHeader = "X-API-Key"
+)
+
+// Principal is the authenticated identity of a request.
+type Principal interface {
+	// Subject identifies the principal, i.e a user or client id.
+	Subject() string
+}
+
+// Authenticator authenticates requests with one kind of credentials.
+// Implementations must be safe for concurrent use.
+type Authenticator interface {
+	// Authenticate returns the principal identified by the request's credentials. It returns ErrNoCredentials
+	// if the request does not carry credentials of its kind.
+	Authenticate(r *http.Request) (Principal, error)
+	// Challenge returns the WWW-Authenticate challenge sent when authentication fails with err, or an empty string
+	// if the authenticator has no challenge. err is nil if the request carried no credentials.
+	Challenge(err error) string
+}
+
+type principalKey struct{}
+
+// bearerKey marks requests authenticated with a bearer token.
+type bearerKey struct{}
+
+// ContextPrincipal returns the principal stored in ctx, or nil if the request was not authenticated.
+func ContextPrincipal(ctx context.Context) Principal {
+	p, _ := ctx.Value(principalKey{}).(Principal)
+	return p
+}
+
+// ContextWithPrincipal returns a copy of ctx carrying p, i.e to test handlers and policies.
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalAs returns the request's principal as P, i.e glhf.PrincipalAs[*User](r).
+// ok is false if the request was not authenticated or its principal is not a P.
+func PrincipalAs[P Principal, T Body](req *Request[T]) (principal P, ok bool) {
+	principal, ok = req.Principal().(P)
+	return principal, ok
+}
+
+// authenticate returns the request with the principal of the first authenticator that finds credentials.
+// Requests without valid credentials fail with 401 and the authenticators' challenges.
+func authenticate(w http.ResponseWriter, r *http.Request, authenticators []Authenticator) (*http.Request, *errorResponse) {
+	for _, a := range authenticators {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err == nil && p != nil {
+			ctx := ContextWithPrincipal(r.Context(), p)
+			if _, ok := a.(*jwtAuthenticator); ok {
+				ctx = context.WithValue(ctx, bearerKey{}, true)
+			}
+			return r.WithContext(ctx), nil
+		}
+		if err == nil {
+			err = ErrInvalidCredentials
+		}
+
+		// the request's credentials are invalid, only the failing authenticator challenges
+		if challenge := a.Challenge(err); len(challenge) > 0 {
+			w.Header().Add(WWWAuthenticate, challenge)
+		}
+		return nil, &errorResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "invalid credentials: " + err.Error(),
+		}
+	}
+
+	for _, a := range authenticators {
+		if challenge := a.Challenge(nil); len(challenge) > 0 {
+			w.Header().Add(WWWAuthenticate, challenge)
+		}
+	}
+	return nil, &errorResponse{
+		Code:    http.StatusUnauthorized,
+		Message: "missing credentials",
+	}
+}
+
+// APIKeyFunc returns the principal owning key.
+type APIKeyFunc func(ctx context.Context, key string) (Principal, error)
+
+type apiKeyAuthenticator struct {
+	header   string
+	validate APIKeyFunc
+}
+
+// NewAPIKeyAuthenticator returns an Authenticator reading API keys from header, X-API-Key if header is empty.
+func NewAPIKeyAuthenticator(header string, validate APIKeyFunc) Authenticator {
+	if len(header) == 0 {
+		header = APIKeyHeader
+	}
+	return &apiKeyAuthenticator{header: header, validate: validate}
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get(a.header)
+	if len(key) == 0 {
+		return nil, ErrNoCredentials
+	}
+	return a.validate(r.Context(), key)
+}
+
+func (a *apiKeyAuthenticator) Challenge(error) string {
+	return "APIKey header=" + strconv.Quote(a.header)
+}
+
+// BasicFunc returns the principal identified by username and password.
+type BasicFunc func(ctx context.Context, username string, password string) (Principal, error)
+
+type basicAuthenticator struct {
+	realm    string
+	validate BasicFunc
+}
+
+// NewBasicAuthenticator returns an Authenticator for HTTP Basic credentials (RFC 7617).
+func NewBasicAuthenticator(realm string, validate BasicFunc) Authenticator {
+	return &basicAuthenticator{realm: realm, validate: validate}
+}
+
+func (a *basicAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	scheme, _, _ := strings.Cut(r.Header.Get(Authorization), " ")
+	if !strings.EqualFold(scheme, "Basic") {
+		return nil, ErrNoCredentials
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return a.validate(r.Context(), username, password)
+}
+
+func (a *basicAuthenticator) Challenge(error) string {
+	return "Basic realm=" + strconv.Quote(a.realm) + `, charset="UTF-8"`
+}
+
+// BasicUsers returns a BasicFunc accepting the username and password pairs of users.
+// The principal is a Subject named after the username.
+func BasicUsers(users map[string]string) BasicFunc {
+	return func(_ context.Context, username string, password string) (Principal, error) {
+		expected, ok := users[username]
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(expected)) != 1 {
+			return nil, ErrInvalidCredentials
+		}
+		return Subject(username), nil
+	}
+}
+
+// CertificateFunc returns the principal identified by a verified client certificate.
+type CertificateFunc func(cert *x509.Certificate) (Principal, error)
+
+type certificateAuthenticator struct {
+	validate CertificateFunc
+}
+
+// NewCertificateAuthenticator returns an Authenticator for mutual TLS. The server's tls.Config must verify client
+// certificates, requests with unverified certificates are rejected. If validate is nil, the principal is a
+// CertificatePrincipal.
+func NewCertificateAuthenticator(validate CertificateFunc) Authenticator {
+	if validate == nil {
+		validate = func(cert *x509.Certificate) (Principal, error) {
+			return CertificatePrincipal{Certificate: cert}, nil
+		}
+	}
+	return &certificateAuthenticator{validate: validate}
+}
+
+func (a *certificateAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, ErrNoCredentials
+	}
+	if len(r.TLS.VerifiedChains) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	return a.validate(r.TLS.PeerCertificates[0])
+}
+
+// Challenge returns no challenge, client certificates are negotiated by TLS.
+func (a *certificateAuthenticator) Challenge(error) string {
+	return ""
+}
+
+// Subject is a Principal only identified by its subject.
+type Subject string
+
+// Subject returns s.
+func (s Subject) Subject() string {
+	return string(s)
+}
+
+// CertificatePrincipal is the principal of a request authenticated with a client certificate.
+type CertificatePrincipal struct {
+	Certificate *x509.Certificate
+}
+
+// Subject returns the certificate's common name.
+func (p CertificatePrincipal) Subject() string {
+	return p.Certificate.Subject.CommonName
+}
@@ -0,0 +1,140 @@
+package glhf
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testUser struct {
+	name string
+}
+
+func (u *testUser) Subject() string {
+	return u.name
+}
+
+func TestAuth(t *testing.T) {
+	apiKeys := NewAPIKeyAuthenticator("", func(ctx context.Context, key string) (Principal, error) {
+		if key != "key-1" {
+			return nil, ErrInvalidCredentials
+		}
+		return &testUser{name: "service"}, nil
+	})
+	basic := NewBasicAuthenticator("glhf", BasicUsers(map[string]string{"alice": "secret"}))
+
+	var subject string
+	handler := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		subject = r.Principal().Subject()
+	}, WithAuth(apiKeys, basic, NewCertificateAuthenticator(nil)))
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}}
+
+	tests := []struct {
+		name       string
+		setup      func(r *http.Request)
+		statusCode int
+		subject    string
+		challenges []string
+	}{
+		{
+			name:       "api key",
+			setup:      func(r *http.Request) { r.Header.Set(APIKeyHeader, "key-1") },
+			statusCode: http.StatusOK,
+			subject:    "service",
+		},
+		{
+			name:       "invalid api key",
+			setup:      func(r *http.Request) { r.Header.Set(APIKeyHeader, "key-2") },
+			statusCode: http.StatusUnauthorized,
+			challenges: []string{`APIKey header="X-API-Key"`},
+		},
+		{
+			name:       "basic",
+			setup:      func(r *http.Request) { r.SetBasicAuth("alice", "secret") },
+			statusCode: http.StatusOK,
+			subject:    "alice",
+		},
+		{
+			name:       "invalid basic",
+			setup:      func(r *http.Request) { r.SetBasicAuth("alice", "guess") },
+			statusCode: http.StatusUnauthorized,
+			challenges: []string{`Basic realm="glhf", charset="UTF-8"`},
+		},
+		{
+			name: "client certificate",
+			setup: func(r *http.Request) {
+				r.TLS = &tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{cert},
+					VerifiedChains:   [][]*x509.Certificate{{cert}},
+				}
+			},
+			statusCode: http.StatusOK,
+			subject:    "client",
+		},
+		{
+			name: "unverified client certificate",
+			setup: func(r *http.Request) {
+				r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "missing credentials",
+			setup:      func(r *http.Request) {},
+			statusCode: http.StatusUnauthorized,
+			challenges: []string{`APIKey header="X-API-Key"`, `Basic realm="glhf", charset="UTF-8"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject = ""
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.setup(r)
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != tt.statusCode {
+				t.Fatalf("status = %d; expected %d", w.Code, tt.statusCode)
+			}
+			if subject != tt.subject {
+				t.Errorf("subject = %s; expected %s", subject, tt.subject)
+			}
+			challenges := w.Header().Values(WWWAuthenticate)
+			if len(challenges) != len(tt.challenges) {
+				t.Fatalf("challenges = %q; expected %q", challenges, tt.challenges)
+			}
+			for i := range challenges {
+				if challenges[i] != tt.challenges[i] {
+					t.Errorf("challenge = %s; expected %s", challenges[i], tt.challenges[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPrincipalAs(t *testing.T) {
+	apiKeys := NewAPIKeyAuthenticator("", func(ctx context.Context, key string) (Principal, error) {
+		return &testUser{name: key}, nil
+	})
+
+	var (
+		user *testUser
+		ok   bool
+	)
+	handler := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		user, ok = PrincipalAs[*testUser](r)
+	}, WithAuth(apiKeys))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(APIKeyHeader, "key-1")
+	handler(httptest.NewRecorder(), r)
+	if !ok || user.name != "key-1" {
+		t.Errorf("PrincipalAs = %v, %t; expected key-1", user, ok)
+	}
+}
@@ -0,0 +1,112 @@
+package glhf
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ScopedPrincipal is a Principal granted OAuth scopes, i.e Claims.
+type ScopedPrincipal interface {
+	Principal
+	Scopes() []string
+}
+
+// RolePrincipal is a Principal with roles, i.e Claims.
+type RolePrincipal interface {
+	Principal
+	Roles() []string
+}
+
+// Policy authorizes a request once its body is decoded, i.e "users can only update their own todo".
+// It returns an error describing why the request is forbidden.
+type Policy[I Body] func(req *Request[I]) error
+
+// principalPolicy authorizes a request's principal before its body is read.
+type principalPolicy func(p Principal) error
+
+// requireScopes returns a principalPolicy requiring all scopes.
+func requireScopes(scopes []string) principalPolicy {
+	return func(p Principal) error {
+		sp, _ := p.(ScopedPrincipal)
+		var granted []string
+		if sp != nil {
+			granted = sp.Scopes()
+		}
+		for _, scope := range scopes {
+			if !containsString(granted, scope) {
+				return fmt.Errorf("%w: %s", ErrMissingScope, scope)
+			}
+		}
+		return nil
+	}
+}
+
+// requireRoles returns a principalPolicy requiring any of roles.
+func requireRoles(roles []string) principalPolicy {
+	return func(p Principal) error {
+		if rp, ok := p.(RolePrincipal); ok {
+			for _, role := range rp.Roles() {
+				if containsString(roles, role) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%w: %v", ErrMissingRole, roles)
+	}
+}
+
+// bodyPolicies returns the policies of opts for handlers decoding I. It panics if a policy is for another body type,
+// silently skipping it would leave the handler unprotected.
+func bodyPolicies[I Body](opts *opts) []Policy[I] {
+	policies := make([]Policy[I], 0, len(opts.policies))
+	for _, p := range opts.policies {
+		policy, ok := p.(Policy[I])
+		if !ok {
+			var body I
+			panic(fmt.Sprintf("glhf: policy %T can not authorize requests with body %T", p, body))
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+// authorizePrincipal evaluates the scope and role requirements of opts.
+func authorizePrincipal(w http.ResponseWriter, r *http.Request, opts *opts) *errorResponse {
+	p := ContextPrincipal(r.Context())
+	for _, policy := range opts.principalPolicies {
+		if err := policy(p); err != nil {
+			return denied(w, p, opts.authenticators, err)
+		}
+	}
+	return nil
+}
+
+// authorizeRequest evaluates the body policies of a decoded request.
+func authorizeRequest[I Body](w http.ResponseWriter, req *Request[I], policies []Policy[I], authenticators []Authenticator) *errorResponse {
+	for _, policy := range policies {
+		if err := policy(req); err != nil {
+			return denied(w, req.Principal(), authenticators, err)
+		}
+	}
+	return nil
+}
+
+// denied returns the response of a request rejected by a policy. Requests without a principal fail with 401 and the
+// authenticators' challenges, authenticating could satisfy the policy, others fail with 403.
+func denied(w http.ResponseWriter, p Principal, authenticators []Authenticator, err error) *errorResponse {
+	if p != nil {
+		return &errorResponse{
+			Code:    http.StatusForbidden,
+			Message: "forbidden: " + err.Error(),
+		}
+	}
+	for _, a := range authenticators {
+		if challenge := a.Challenge(nil); len(challenge) > 0 {
+			w.Header().Add(WWWAuthenticate, challenge)
+		}
+	}
+	return &errorResponse{
+		Code:    http.StatusUnauthorized,
+		Message: "unauthorized: " + err.Error(),
+	}
+}
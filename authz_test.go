@@ -0,0 +1,120 @@
+package glhf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthorization(t *testing.T) {
+	type todo struct {
+		Owner string `json:"owner"`
+	}
+
+	auth := NewAPIKeyAuthenticator("", func(ctx context.Context, key string) (Principal, error) {
+		sub, scope, _ := strings.Cut(key, ":")
+		return Claims{"sub": sub, "scope": scope, "roles": []any{"user"}}, nil
+	})
+	ownTodo := func(r *Request[todo]) error {
+		if r.Body().Owner != r.Principal().Subject() {
+			return errors.New("todo is owned by another user")
+		}
+		return nil
+	}
+
+	rt := NewRouter()
+	api := rt.Group("/api", WithAuth(auth), WithRoles("user", "admin"))
+	Handle(api, http.MethodPost, "/todo", func(r *Request[todo], w *Response[EmptyBody]) {
+		w.SetStatus(http.StatusCreated)
+	}, WithScopes("todo:write"), WithPolicy(ownTodo))
+
+	tests := []struct {
+		name       string
+		key        string
+		body       string
+		statusCode int
+	}{
+		{name: "allowed", key: "alice:todo:write", body: `{"owner":"alice"}`, statusCode: http.StatusCreated},
+		{name: "missing scope", key: "alice:todo:read", body: `{"owner":"alice"}`, statusCode: http.StatusForbidden},
+		{name: "policy", key: "alice:todo:write", body: `{"owner":"bob"}`, statusCode: http.StatusForbidden},
+		{name: "unauthenticated", body: `{"owner":"alice"}`, statusCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/todo", strings.NewReader(tt.body))
+			r.Header.Set(ContentType, ContentJSON)
+			if len(tt.key) > 0 {
+				r.Header.Set(APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, r)
+			if w.Code != tt.statusCode {
+				t.Errorf("status = %d; expected %d", w.Code, tt.statusCode)
+			}
+		})
+	}
+}
+
+func TestAuthorizationWithoutPrincipal(t *testing.T) {
+	withPrincipal := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if key := r.Header.Get(APIKeyHeader); len(key) > 0 {
+				r = r.WithContext(ContextWithPrincipal(r.Context(), Claims{"sub": key}))
+			}
+			h(w, r)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		options    []Options
+		key        string
+		statusCode int
+	}{
+		{name: "scope", options: []Options{WithScopes("todo:write")}, statusCode: http.StatusUnauthorized},
+		{name: "role", options: []Options{WithRoles("admin")}, statusCode: http.StatusUnauthorized},
+		{name: "policy", options: []Options{WithPolicy(func(r *Request[EmptyBody]) error { return ErrMissingRole })}, statusCode: http.StatusUnauthorized},
+		{name: "principal", options: []Options{WithScopes("todo:write")}, key: "alice", statusCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := withPrincipal(Post(func(r *Request[EmptyBody], w *Response[EmptyBody]) {}, tt.options...))
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			if len(tt.key) > 0 {
+				r.Header.Set(APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.statusCode {
+				t.Errorf("status = %d; expected %d", w.Code, tt.statusCode)
+			}
+		})
+	}
+
+	auth := NewAPIKeyAuthenticator("", func(ctx context.Context, key string) (Principal, error) {
+		return Claims{"sub": key}, nil
+	})
+	w := httptest.NewRecorder()
+	o := &opts{authenticators: []Authenticator{auth}, principalPolicies: []principalPolicy{requireScopes([]string{"todo:write"})}}
+	errResp := authorizePrincipal(w, httptest.NewRequest(http.MethodPost, "/", nil), o)
+	if errResp == nil || errResp.Code != http.StatusUnauthorized {
+		t.Fatalf("error = %v; expected %d", errResp, http.StatusUnauthorized)
+	}
+	if challenge := w.Header().Get(WWWAuthenticate); challenge != auth.Challenge(nil) {
+		t.Errorf("challenge = %q; expected %q", challenge, auth.Challenge(nil))
+	}
+}
+
+func TestPolicyBodyMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a policy for another body type to panic")
+		}
+	}()
+	Post(func(r *Request[EmptyBody], w *Response[EmptyBody]) {}, WithPolicy(func(r *Request[Claims]) error { return nil }))
+}
@@ -0,0 +1,226 @@
+package glhf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ETag header constant.
+	ETag = "ETag"
+	// LastModified header constant.
+	LastModified = "Last-Modified"
+	// CacheControl header constant.
+	CacheControl = "Cache-Control"
+	// IfMatch header constant.
+	IfMatch = "If-Match"
+	// IfNoneMatch header constant.
+	IfNoneMatch = "If-None-Match"
+	// IfModifiedSince header constant.
+	IfModifiedSince = "If-Modified-Since"
+	// IfUnmodifiedSince header constant.
+	IfUnmodifiedSince = "If-Unmodified-Since"
+)
+
+// ETagMode defines how glhf computes an ETag from the encoded response body.
+type ETagMode int
+
+const (
+	// NoETag disables computed ETags.
+	NoETag ETagMode = iota
+	// StrongETag computes a strong ETag, the response bytes are identical for the same ETag.
+	StrongETag
+	// WeakETag computes a weak ETag, the response is semantically equivalent for the same ETag.
+	WeakETag
+)
+
+// CachePolicy is the Cache-Control policy of a route (RFC 9111). It is applied to successful GET and HEAD responses
+// that do not set their own Cache-Control header.
+type CachePolicy struct {
+	// MaxAge is the max-age directive, the duration a response is considered fresh.
+	MaxAge time.Duration
+	// SharedMaxAge is the s-maxage directive, the max-age used by shared caches.
+	SharedMaxAge time.Duration
+	// StaleWhileRevalidate is the duration a stale response may be served while it is revalidated (RFC 5861).
+	StaleWhileRevalidate time.Duration
+	// StaleIfError is the duration a stale response may be served if revalidation fails (RFC 5861).
+	StaleIfError time.Duration
+	// Public allows shared caches to store the response.
+	Public bool
+	// Private prevents shared caches from storing the response.
+	Private bool
+	// NoCache requires caches to revalidate the response before using it.
+	NoCache bool
+	// NoStore prevents caches from storing the response.
+	NoStore bool
+	// MustRevalidate prevents caches from using the response once stale without revalidating it.
+	MustRevalidate bool
+	// Immutable indicates the response will not change while it is fresh.
+	Immutable bool
+}
+
+// String returns the Cache-Control header value of the policy.
+func (p CachePolicy) String() string {
+	var directives []string
+	if p.Public {
+		directives = append(directives, "public")
+	}
+	if p.Private {
+		directives = append(directives, "private")
+	}
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	}
+	if p.NoStore {
+		directives = append(directives, "no-store")
+	}
+	if p.MaxAge > 0 {
+		directives = append(directives, "max-age="+seconds(p.MaxAge))
+	}
+	if p.SharedMaxAge > 0 {
+		directives = append(directives, "s-maxage="+seconds(p.SharedMaxAge))
+	}
+	if p.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+	if p.Immutable {
+		directives = append(directives, "immutable")
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+seconds(p.StaleWhileRevalidate))
+	}
+	if p.StaleIfError > 0 {
+		directives = append(directives, "stale-if-error="+seconds(p.StaleIfError))
+	}
+	return strings.Join(directives, ", ")
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+// Validators are the current validators of a resource, used to evaluate conditional requests.
+type Validators struct {
+	// ETag is the current entity-tag of the resource, i.e "v1" or W/"v1". Unquoted values are quoted.
+	ETag string
+	// LastModified is the time the resource was last modified.
+	LastModified time.Time
+}
+
+// ValidatorFunc returns the current validators of the resource targeted by the request. It is called before
+// the handler so preconditions can be evaluated before the resource is read or modified.
+// Zero Validators indicate the resource does not exist.
+type ValidatorFunc func(*http.Request) (Validators, error)
+
+// quoteETag returns etag as an entity-tag, quoting it if needed.
+func quoteETag(etag string) string {
+	if len(etag) == 0 || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return strconv.Quote(etag)
+}
+
+// computeETag returns an entity-tag derived from the encoded response body.
+func computeETag(mode ETagMode, b []byte) string {
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if mode == WeakETag {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// isSafe reports whether the method only retrieves a representation.
+func isSafe(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// setCacheHeaders sets the ETag and Cache-Control headers of a successful GET or HEAD response.
+func setCacheHeaders(w http.ResponseWriter, r *http.Request, opts *opts, statusCode int, b []byte) {
+	if !isSafe(r.Method) || statusCode < 200 || statusCode > 299 {
+		return
+	}
+
+	if opts.etagMode != NoETag && len(b) > 0 && len(w.Header().Get(ETag)) == 0 {
+		w.Header().Set(ETag, computeETag(opts.etagMode, b))
+	}
+	if opts.cachePolicy != nil && len(w.Header().Get(CacheControl)) == 0 {
+		w.Header().Set(CacheControl, opts.cachePolicy.String())
+	}
+}
+
+// checkPreconditions evaluates the request's conditional headers against the current validators of a resource,
+// following the order of RFC 9110 section 13.2.2. It returns 0 if the request should proceed, otherwise
+// http.StatusNotModified or http.StatusPreconditionFailed.
+func checkPreconditions(r *http.Request, etag string, lastModified time.Time) int {
+	exists := len(etag) > 0 || !lastModified.IsZero()
+	lastModified = lastModified.Truncate(time.Second)
+
+	if im := r.Header.Get(IfMatch); len(im) > 0 {
+		if !matchETag(im, etag, exists, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := r.Header.Get(IfUnmodifiedSince); len(ius) > 0 && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ius); err == nil && lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := r.Header.Get(IfNoneMatch); len(inm) > 0 {
+		if matchETag(inm, etag, exists, false) {
+			if isSafe(r.Method) {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := r.Header.Get(IfModifiedSince); len(ims) > 0 && isSafe(r.Method) && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// matchETag reports whether etag matches one of the entity-tags in header. Strong comparison requires both
+// entity-tags to be strong, weak comparison ignores the weak indicator.
+func matchETag(header string, etag string, exists bool, strong bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return exists
+	}
+	if len(etag) == 0 || (strong && strings.HasPrefix(etag, "W/")) {
+		return false
+	}
+
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strong && strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified writes a 304 response, keeping the validator and caching headers.
+func writeNotModified(w http.ResponseWriter) {
+	w.Header().Del(ContentType)
+	w.Header().Del(ContentEncoding)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// lastModifiedHeader returns the Last-Modified header of the response, or the zero time if it is not set.
+func lastModifiedHeader(h http.Header) time.Time {
+	t, err := http.ParseTime(h.Get(LastModified))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,154 @@
+package glhf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	modified := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		method   string
+		header   string
+		value    string
+		expected int
+	}{
+		{"if-none-match match", http.MethodGet, IfNoneMatch, `"a", W/"v1"`, http.StatusNotModified},
+		{"if-none-match mismatch", http.MethodGet, IfNoneMatch, `"v2"`, 0},
+		{"if-none-match unsafe", http.MethodPut, IfNoneMatch, `*`, http.StatusPreconditionFailed},
+		{"if-modified-since unchanged", http.MethodGet, IfModifiedSince, modified.Format(http.TimeFormat), http.StatusNotModified},
+		{"if-modified-since changed", http.MethodGet, IfModifiedSince, modified.Add(-time.Hour).Format(http.TimeFormat), 0},
+		{"if-match match", http.MethodPut, IfMatch, `"v1"`, 0},
+		{"if-match weak", http.MethodPut, IfMatch, `W/"v1"`, http.StatusPreconditionFailed},
+		{"if-match mismatch", http.MethodDelete, IfMatch, `"v2"`, http.StatusPreconditionFailed},
+		{"if-unmodified-since changed", http.MethodPatch, IfUnmodifiedSince, modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusPreconditionFailed},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r := httptest.NewRequest(testCase.method, "/", nil)
+			r.Header.Set(testCase.header, testCase.value)
+
+			if actual := checkPreconditions(r, `"v1"`, modified); actual != testCase.expected {
+				t.Errorf("checkPreconditions() = %d; expected %d", actual, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	type message struct {
+		Text string `json:"text"`
+	}
+
+	handler := Get(func(r *Request[EmptyBody], w *Response[message]) {
+		w.SetBody(&message{Text: "glhf"})
+	}, WithETag(StrongETag), WithCachePolicy(CachePolicy{MaxAge: time.Minute, Public: true}))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := w.Header().Get(ETag)
+	if w.Code != http.StatusOK || len(etag) == 0 {
+		t.Fatalf("status = %d, ETag = %q; expected 200 with an ETag", w.Code, etag)
+	}
+	if actual := w.Header().Get(CacheControl); actual != "public, max-age=60" {
+		t.Errorf("Cache-Control = %q; expected %q", actual, "public, max-age=60")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(IfNoneMatch, etag)
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusNotModified || w.Body.Len() > 0 {
+		t.Errorf("status = %d, body = %q; expected 304 without a body", w.Code, w.Body.String())
+	}
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	type todo struct {
+		ID string `json:"id"`
+	}
+
+	version := "v1"
+	handler := Put(func(r *Request[todo], w *Response[todo]) {
+		if !w.CheckVersion(version) {
+			return
+		}
+		version = "v2"
+		w.SetETag(version)
+	}, WithPreconditionRequired(true))
+
+	testCases := []struct {
+		name              string
+		ifMatch           string
+		ifUnmodifiedSince string
+		expected          int
+	}{
+		{"missing", "", "", http.StatusPreconditionRequired},
+		// CheckVersion can not evaluate If-Unmodified-Since, it does not satisfy the precondition requirement
+		{"unmodified since", "", time.Now().UTC().Format(http.TimeFormat), http.StatusPreconditionRequired},
+		{"mismatch", `"v0"`, "", http.StatusPreconditionFailed},
+		{"match", `"v1"`, "", http.StatusOK},
+		{"stale", `"v1"`, "", http.StatusPreconditionFailed},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"id":"1"}`))
+			r.Header.Set(ContentType, ContentJSON)
+			if len(testCase.ifMatch) > 0 {
+				r.Header.Set(IfMatch, testCase.ifMatch)
+			}
+			if len(testCase.ifUnmodifiedSince) > 0 {
+				r.Header.Set(IfUnmodifiedSince, testCase.ifUnmodifiedSince)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != testCase.expected {
+				t.Errorf("status = %d; expected %d", w.Code, testCase.expected)
+			}
+			if w.Code == http.StatusOK && w.Header().Get(ETag) != `"v2"` {
+				t.Errorf("ETag = %q; expected %q", w.Header().Get(ETag), `"v2"`)
+			}
+		})
+	}
+}
+
+func TestPreconditionRequiredValidators(t *testing.T) {
+	modified := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	handler := Put(func(r *Request[map[string]string], w *Response[EmptyBody]) {}, WithPreconditionRequired(true),
+		WithValidators(func(r *http.Request) (Validators, error) {
+			return Validators{ETag: "v1", LastModified: modified}, nil
+		}))
+
+	testCases := []struct {
+		name              string
+		ifUnmodifiedSince time.Time
+		expected          int
+	}{
+		{"missing", time.Time{}, http.StatusPreconditionRequired},
+		{"unmodified", modified, http.StatusOK},
+		{"modified", modified.Add(-time.Hour), http.StatusPreconditionFailed},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{}`))
+			r.Header.Set(ContentType, ContentJSON)
+			if !testCase.ifUnmodifiedSince.IsZero() {
+				r.Header.Set(IfUnmodifiedSince, testCase.ifUnmodifiedSince.Format(http.TimeFormat))
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != testCase.expected {
+				t.Errorf("status = %d; expected %d", w.Code, testCase.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package glhf
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec marshals and unmarshals request and response bodies for a media type.
+type Codec interface {
+	// Marshal encodes v, a pointer to the response body.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into v, a pointer to the request body.
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{}
+)
+
+func init() {
+	RegisterCodec(jsonCodec{}, ContentJSON)
+	RegisterCodec(protoCodec{}, ContentProto, ContentXProtobuf, ContentProtobuf, ContentVndProtobuf)
+	RegisterCodec(msgpackCodec{}, ContentMsgpack, ContentXMsgpack)
+	RegisterCodec(cborCodec{}, ContentCBOR)
+}
+
+// RegisterCodec registers a codec for the given media types. Registering several media types
+// for the same codec makes them aliases of each other, a request using any of them is decoded
+// with the codec and a response is encoded with it and labeled with the media type the client asked for.
+// Registering a media type that is already registered replaces its codec.
+func RegisterCodec(c Codec, mediaTypes ...string) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	for _, mt := range mediaTypes {
+		codecs[strings.ToLower(mt)] = c
+	}
+}
+
+// LookupCodec returns the media type of contentType, without parameters, and the codec registered for it.
+// ok is false if contentType is malformed or no codec is registered for it.
+func LookupCodec(contentType string) (mediaType string, c Codec, ok bool) {
+	return lookupCodec(contentType)
+}
+
+// lookupCodec returns the media type, without parameters, and the codec registered for contentType.
+func lookupCodec(contentType string) (string, Codec, bool) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", nil, false
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	c, ok := codecs[mt]
+	return mt, c, ok
+}
+
+// acceptedMediaTypes returns the media types of an Accept header ordered by the client's preference.
+// Wildcard ranges and media types with a quality of zero are omitted.
+func acceptedMediaTypes(accept string) []string {
+	mediaTypes := []string{}
+	for _, r := range parseAccept(accept) {
+		if r.q <= 0 || strings.HasSuffix(r.value, "/*") {
+			continue
+		}
+		mediaTypes = append(mediaTypes, r.value)
+	}
+	return mediaTypes
+}
+
+// acceptRange is a member of an Accept or Accept-Encoding header.
+type acceptRange struct {
+	value string
+	q     float64
+}
+
+// parseAccept returns the members of an Accept style header, lowercased and ordered by quality. Members with a
+// quality of zero are kept, they mark the value as not acceptable. Members with an invalid quality are omitted.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		value, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{value: value, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges
+}
+
+// jsonCodec implements Codec using encoding/json.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// protoCodec implements Codec for bodies that implement proto.Message.
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrProto
+	}
+	return proto.Marshal(msg)
+}
+
+func (protoCodec) Unmarshal(data []byte, v any) error {
+	// msg pointer matches body
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return ErrProto
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// msgpackCodec implements Codec using MessagePack. Fields without a msgpack struct tag use their json tag.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// cborCodec implements Codec using CBOR (RFC 8949). Fields without a cbor struct tag use their json tag.
+type cborCodec struct{}
+
+func (cborCodec) Marshal(v any) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+func (cborCodec) Unmarshal(data []byte, v any) error {
+	return cbor.Unmarshal(data, v)
+}
@@ -0,0 +1,111 @@
+package glhf
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestAcceptedMediaTypes(t *testing.T) {
+	testCases := []struct {
+		accept   string
+		expected []string
+	}{
+		{"", []string{}},
+		{"application/json", []string{"application/json"}},
+		{"application/json;q=0.5, application/x-protobuf", []string{"application/x-protobuf", "application/json"}},
+		{"*/*, text/*, application/proto;q=0", []string{}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.accept, func(t *testing.T) {
+			actual := acceptedMediaTypes(testCase.accept)
+			if !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("acceptedMediaTypes(%q) = %v; expected %v", testCase.accept, actual, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestProtoAliases(t *testing.T) {
+	handler := Post(func(r *Request[wrapperspb.StringValue], w *Response[wrapperspb.StringValue]) {
+		w.SetBody(r.Body())
+	})
+
+	for _, mediaType := range []string{ContentProto, ContentXProtobuf, ContentProtobuf, ContentVndProtobuf} {
+		t.Run(mediaType, func(t *testing.T) {
+			b, err := proto.Marshal(wrapperspb.String("glhf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+			r.Header.Set(ContentType, mediaType)
+			r.Header.Set(Accept, mediaType)
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d; expected %d", w.Code, http.StatusOK)
+			}
+			if actual := w.Header().Get(ContentType); actual != mediaType {
+				t.Errorf("Content-Type = %q; expected %q", actual, mediaType)
+			}
+			if actual := w.Header().Get(Vary); actual != Accept {
+				t.Errorf("Vary = %q; expected %q", actual, Accept)
+			}
+			msg := &wrapperspb.StringValue{}
+			if err := proto.Unmarshal(w.Body.Bytes(), msg); err != nil || msg.Value != "glhf" {
+				t.Errorf("response body = %v, %v; expected glhf", msg, err)
+			}
+		})
+	}
+}
+
+func TestBinaryCodecs(t *testing.T) {
+	type todo struct {
+		ID   string `json:"id"`
+		Done bool   `json:"done,omitempty"`
+	}
+
+	handler := Post(func(r *Request[todo], w *Response[todo]) {
+		w.SetBody(r.Body())
+	})
+
+	for _, mediaType := range []string{ContentMsgpack, ContentCBOR} {
+		t.Run(mediaType, func(t *testing.T) {
+			_, codec, ok := lookupCodec(mediaType)
+			if !ok {
+				t.Fatalf("no codec registered for %s", mediaType)
+			}
+
+			// encode with json tag names so the codec must honour them to decode
+			b, err := codec.Marshal(map[string]any{"id": "1", "done": true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+			r.Header.Set(ContentType, mediaType)
+			r.Header.Set(Accept, mediaType)
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d; expected %d", w.Code, http.StatusOK)
+			}
+			actual := map[string]any{}
+			if err := codec.Unmarshal(w.Body.Bytes(), &actual); err != nil {
+				t.Fatal(err)
+			}
+			if actual["id"] != "1" || actual["done"] != true {
+				t.Errorf("response body = %v; expected id=1 done=true", actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package glhf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// AcceptEncoding header constant.
+	AcceptEncoding = "Accept-Encoding"
+	// ContentEncoding header constant.
+	ContentEncoding = "Content-Encoding"
+	// Vary header constant.
+	Vary = "Vary"
+
+	// EncodingGzip content-coding value for gzip.
+	EncodingGzip = "gzip"
+	// EncodingDeflate content-coding value for zlib wrapped deflate.
+	EncodingDeflate = "deflate"
+	// EncodingBrotli content-coding value for brotli.
+	EncodingBrotli = "br"
+	// EncodingZstd content-coding value for zstandard.
+	EncodingZstd = "zstd"
+
+	// defaultCompressionThreshold is the minimum response size, in bytes, that is compressed.
+	defaultCompressionThreshold = 1024
+)
+
+// Compressor compresses response bodies for a single content-coding.
+type Compressor interface {
+	// Encoding returns the content-coding token, i.e gzip.
+	Encoding() string
+	// Compress returns the compressed form of b.
+	Compress(b []byte) ([]byte, error)
+}
+
+var (
+	// defaultCompressors are used by WithCompression when no compressors are supplied.
+	defaultCompressors = []Compressor{
+		NewGzipCompressor(gzip.DefaultCompression),
+		NewDeflateCompressor(zlib.DefaultCompression),
+	}
+
+	// defaultCompressibleTypes are the response media types compressed by default.
+	defaultCompressibleTypes = []string{
+		"text/*",
+		ContentJSON,
+		ContentXHTML,
+		"application/xml",
+		"application/javascript",
+		"application/*+json",
+		"application/*+xml",
+	}
+)
+
+// resetWriter is a compressing writer that can be reused for a new destination.
+type resetWriter interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+// poolCompressor is a Compressor backed by a pool of reusable writers.
+type poolCompressor struct {
+	encoding string
+	writers  sync.Pool
+}
+
+func (c *poolCompressor) Encoding() string {
+	return c.encoding
+}
+
+func (c *poolCompressor) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := c.writers.Get().(resetWriter)
+	defer c.writers.Put(zw)
+
+	zw.Reset(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewGzipCompressor returns a gzip Compressor with a pool of writers using the given compression level.
+// An invalid level falls back to gzip.DefaultCompression.
+func NewGzipCompressor(level int) Compressor {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		level = gzip.DefaultCompression
+	}
+
+	c := &poolCompressor{encoding: EncodingGzip}
+	c.writers.New = func() any {
+		zw, _ := gzip.NewWriterLevel(io.Discard, level)
+		return zw
+	}
+	return c
+}
+
+// NewDeflateCompressor returns a deflate Compressor with a pool of writers using the given compression level.
+// An invalid level falls back to zlib.DefaultCompression.
+func NewDeflateCompressor(level int) Compressor {
+	if _, err := zlib.NewWriterLevel(io.Discard, level); err != nil {
+		level = zlib.DefaultCompression
+	}
+
+	c := &poolCompressor{encoding: EncodingDeflate}
+	c.writers.New = func() any {
+		zw, _ := zlib.NewWriterLevel(io.Discard, level)
+		return zw
+	}
+	return c
+}
+
+// NewBrotliCompressor returns a brotli Compressor with a pool of writers using the given quality (0-11).
+func NewBrotliCompressor(quality int) Compressor {
+	c := &poolCompressor{encoding: EncodingBrotli}
+	c.writers.New = func() any {
+		return brotli.NewWriterLevel(io.Discard, quality)
+	}
+	return c
+}
+
+// zstdCompressor is a zstd Compressor. A zstd encoder is safe for concurrent use, no pool is required.
+type zstdCompressor struct {
+	enc *zstd.Encoder
+}
+
+// NewZstdCompressor returns a zstd Compressor using the given zstd compression level (1-22).
+func NewZstdCompressor(level int) Compressor {
+	enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	return &zstdCompressor{enc: enc}
+}
+
+func (c *zstdCompressor) Encoding() string {
+	return EncodingZstd
+}
+
+func (c *zstdCompressor) Compress(b []byte) ([]byte, error) {
+	return c.enc.EncodeAll(b, nil), nil
+}
+
+// compressResponse compresses b with the compressor negotiated from the request's Accept-Encoding header.
+// b is returned unchanged if compression is disabled, not accepted by the client or not worth it. Clients that
+// refuse the identity coding, i.e with "identity;q=0" or "*;q=0", get a compressed body regardless of its size,
+// or 406 Not Acceptable if they accept none of the compressors.
+func compressResponse(w http.ResponseWriter, r *http.Request, opts *opts, b []byte) ([]byte, *errorResponse) {
+	if len(opts.compressors) == 0 || len(b) == 0 {
+		return b, nil
+	}
+
+	if !compressible(w.Header().Get(ContentType), opts.compressibleTypes) {
+		return b, nil
+	}
+	// the response representation depends on Accept-Encoding from here on
+	w.Header().Add(Vary, AcceptEncoding)
+
+	// body is already encoded by the handler
+	if len(w.Header().Get(ContentEncoding)) > 0 {
+		return b, nil
+	}
+
+	c, identity := negotiateEncoding(r.Header.Get(AcceptEncoding), opts.compressors)
+	if c == nil {
+		if !identity {
+			return nil, &errorResponse{
+				Code:    http.StatusNotAcceptable,
+				Message: "no acceptable content-coding in " + AcceptEncoding,
+			}
+		}
+		return b, nil
+	}
+	if identity && len(b) < opts.compressionThreshold {
+		return b, nil
+	}
+
+	compressed, err := c.Compress(b)
+	if err != nil {
+		// fallback to the uncompressed body
+		return b, nil
+	}
+	w.Header().Set(ContentEncoding, c.Encoding())
+	w.Header().Del("Content-Length")
+	// the compressed bytes differ from the ones a strong ETag was computed from
+	if etag := w.Header().Get(ETag); strings.HasPrefix(etag, `"`) {
+		w.Header().Set(ETag, "W/"+etag)
+	}
+	return compressed, nil
+}
+
+// compressible reports whether contentType matches one of the media type patterns.
+func compressible(contentType string, patterns []string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, mt); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding returns the compressor with the highest quality in the Accept-Encoding header, and whether the
+// client accepts the identity coding. Ties are broken by the order of compressors. Nil is returned if the client
+// accepts none of them.
+func negotiateEncoding(acceptEncoding string, compressors []Compressor) (Compressor, bool) {
+	qualities := map[string]float64{}
+	for _, r := range parseAccept(acceptEncoding) {
+		if _, ok := qualities[r.value]; !ok {
+			qualities[r.value] = r.q
+		}
+	}
+
+	var (
+		best  Compressor
+		bestQ float64
+	)
+	for _, c := range compressors {
+		q, ok := qualities[c.Encoding()]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+
+	// identity is acceptable unless it, or the wildcard without an identity member, has a quality of zero
+	identity, ok := qualities["identity"]
+	if !ok {
+		identity, ok = qualities["*"]
+	}
+	return best, !ok || identity > 0
+}
@@ -0,0 +1,111 @@
+package glhf
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	compressors := []Compressor{NewGzipCompressor(gzip.DefaultCompression), NewBrotliCompressor(4)}
+
+	testCases := []struct {
+		acceptEncoding string
+		expected       string
+		identity       bool
+	}{
+		{"", "", true},
+		{"identity", "", true},
+		{"gzip", EncodingGzip, true},
+		{"br, gzip", EncodingGzip, true},
+		{"gzip;q=0.5, br", EncodingBrotli, true},
+		{"gzip;level=1;q=0.5, br;q=0.8", EncodingBrotli, true},
+		{"GZIP;Q=0.9, br;q=0.8", EncodingGzip, true},
+		{"gzip;q=0, *", EncodingBrotli, true},
+		{"*;q=0", "", false},
+		{"gzip, identity;q=0", EncodingGzip, false},
+		{"gzip, *;q=0", EncodingGzip, false},
+		{"*;q=0, identity", "", true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.acceptEncoding, func(t *testing.T) {
+			var actual string
+			c, identity := negotiateEncoding(testCase.acceptEncoding, compressors)
+			if c != nil {
+				actual = c.Encoding()
+			}
+			if actual != testCase.expected || identity != testCase.identity {
+				t.Errorf("negotiateEncoding(%q) = %q, %t; expected %q, %t", testCase.acceptEncoding, actual, identity,
+					testCase.expected, testCase.identity)
+			}
+		})
+	}
+}
+
+func TestCompressResponse(t *testing.T) {
+	type message struct {
+		Text string `json:"text"`
+	}
+
+	handler := Get(func(r *Request[EmptyBody], w *Response[message]) {
+		w.SetBody(&message{Text: strings.Repeat("glhf", 512)})
+	}, WithCompression())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(AcceptEncoding, "gzip")
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	if actual := w.Header().Get(ContentEncoding); actual != EncodingGzip {
+		t.Fatalf("Content-Encoding = %q; expected %q", actual, EncodingGzip)
+	}
+	if actual := w.Header().Values(Vary); !reflect.DeepEqual(actual, []string{Accept, AcceptEncoding}) {
+		t.Errorf("Vary = %q; expected %q", actual, []string{Accept, AcceptEncoding})
+	}
+
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "glhfglhf") {
+		t.Errorf("decompressed body = %q; expected message", b)
+	}
+}
+
+func TestCompressResponseIdentityRefused(t *testing.T) {
+	handler := Get(func(r *Request[EmptyBody], w *Response[map[string]string]) {
+		w.SetBody(&map[string]string{"hello": "world"})
+	}, WithCompression())
+
+	testCases := []struct {
+		acceptEncoding string
+		status         int
+		encoding       string
+	}{
+		// below the threshold the body is only compressed if identity is refused
+		{"gzip", http.StatusOK, ""},
+		{"gzip, identity;q=0", http.StatusOK, EncodingGzip},
+		{"br, *;q=0", http.StatusNotAcceptable, ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.acceptEncoding, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(AcceptEncoding, testCase.acceptEncoding)
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != testCase.status || w.Header().Get(ContentEncoding) != testCase.encoding {
+				t.Errorf("response = %d, %q; expected %d, %q", w.Code, w.Header().Get(ContentEncoding), testCase.status, testCase.encoding)
+			}
+		})
+	}
+}
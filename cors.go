@@ -0,0 +1,133 @@
+package glhf
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Origin header constant.
+	Origin = "Origin"
+	// AccessControlRequestMethod header constant.
+	AccessControlRequestMethod = "Access-Control-Request-Method"
+	// AccessControlRequestHeaders header constant.
+	AccessControlRequestHeaders = "Access-Control-Request-Headers"
+	// AccessControlAllowOrigin header constant.
+	AccessControlAllowOrigin = "Access-Control-Allow-Origin"
+	// AccessControlAllowMethods header constant.
+	AccessControlAllowMethods = "Access-Control-Allow-Methods"
+	// AccessControlAllowHeaders header constant.
+	AccessControlAllowHeaders = "Access-Control-Allow-Headers"
+	// AccessControlAllowCredentials header constant.
+	AccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	// AccessControlExposeHeaders header constant.
+	AccessControlExposeHeaders = "Access-Control-Expose-Headers"
+	// AccessControlMaxAge header constant.
+	AccessControlMaxAge = "Access-Control-Max-Age"
+)
+
+// CORSConfig configures Cross-Origin Resource Sharing.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to make requests. Patterns are matched with path.Match,
+	// i.e "https://*.example.com", "*" allows any origin and can not be combined with AllowCredentials.
+	AllowedOrigins []string
+	// AllowedMethods are the methods allowed in preflight requests. Defaults to the methods registered for the path.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed in preflight requests. If empty, the headers listed in the
+	// preflight's Access-Control-Request-Headers are reflected, allowing any request header.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers exposed to the client.
+	ExposedHeaders []string
+	// AllowCredentials allows requests with cookies or HTTP authentication.
+	AllowCredentials bool
+	// MaxAge is how long the result of a preflight request may be cached.
+	MaxAge time.Duration
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, ok is false if origin is not allowed.
+func (c *CORSConfig) allowOrigin(origin string) (string, bool) {
+	for _, pattern := range c.AllowedOrigins {
+		if pattern == "*" {
+			return "*", true
+		}
+		if ok, _ := path.Match(pattern, origin); ok {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// setCORSHeaders adds the CORS headers of an actual, non preflight, request.
+func setCORSHeaders(w http.ResponseWriter, r *http.Request, c *CORSConfig) {
+	w.Header().Add(Vary, Origin)
+	origin := r.Header.Get(Origin)
+	if len(origin) == 0 {
+		return
+	}
+	allowed, ok := c.allowOrigin(origin)
+	if !ok {
+		return
+	}
+
+	w.Header().Set(AccessControlAllowOrigin, allowed)
+	if c.AllowCredentials {
+		w.Header().Set(AccessControlAllowCredentials, "true")
+	}
+	if len(c.ExposedHeaders) > 0 {
+		w.Header().Set(AccessControlExposeHeaders, strings.Join(c.ExposedHeaders, ", "))
+	}
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && len(r.Header.Get(Origin)) > 0 &&
+		len(r.Header.Get(AccessControlRequestMethod)) > 0
+}
+
+// preflight answers a CORS preflight request for a path serving methods. Preflights from origins that are not
+// allowed, or for methods that are not allowed, fail with 403.
+func preflight(w http.ResponseWriter, r *http.Request, opts *opts, methods []string) {
+	c := opts.cors
+	w.Header().Add(Vary, Origin)
+	w.Header().Add(Vary, AccessControlRequestMethod)
+	w.Header().Add(Vary, AccessControlRequestHeaders)
+
+	allowed, ok := c.allowOrigin(r.Header.Get(Origin))
+	if !ok {
+		writeError(w, r, opts, &errorResponse{
+			Code:    http.StatusForbidden,
+			Message: "origin " + r.Header.Get(Origin) + " is not allowed",
+		})
+		return
+	}
+
+	if len(c.AllowedMethods) > 0 {
+		methods = c.AllowedMethods
+	}
+	method := r.Header.Get(AccessControlRequestMethod)
+	if !containsString(methods, method) {
+		writeError(w, r, opts, &errorResponse{
+			Code:    http.StatusForbidden,
+			Message: "method " + method + " is not allowed",
+		})
+		return
+	}
+
+	w.Header().Set(AccessControlAllowOrigin, allowed)
+	w.Header().Set(AccessControlAllowMethods, strings.Join(methods, ", "))
+	if len(c.AllowedHeaders) > 0 {
+		w.Header().Set(AccessControlAllowHeaders, strings.Join(c.AllowedHeaders, ", "))
+	} else if requested := r.Header.Get(AccessControlRequestHeaders); len(requested) > 0 {
+		w.Header().Set(AccessControlAllowHeaders, requested)
+	}
+	if c.AllowCredentials {
+		w.Header().Set(AccessControlAllowCredentials, "true")
+	}
+	if c.MaxAge > 0 {
+		w.Header().Set(AccessControlMaxAge, strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+package glhf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	cors := WithCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		ExposedHeaders:   []string{ETag},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})
+
+	rt := NewRouter(cors)
+	Handle(rt, http.MethodGet, "/todo", func(r *Request[EmptyBody], w *Response[EmptyBody]) {})
+	Handle(rt, http.MethodPut, "/todo", func(r *Request[EmptyBody], w *Response[EmptyBody]) {})
+
+	tests := []struct {
+		name       string
+		method     string
+		header     map[string]string
+		statusCode int
+		expected   map[string]string
+	}{
+		{
+			name:   "preflight",
+			method: http.MethodOptions,
+			header: map[string]string{
+				Origin:                      "https://app.example.com",
+				AccessControlRequestMethod:  http.MethodPut,
+				AccessControlRequestHeaders: "content-type",
+			},
+			statusCode: http.StatusNoContent,
+			expected: map[string]string{
+				AccessControlAllowOrigin:      "https://app.example.com",
+				AccessControlAllowMethods:     "GET, HEAD, PUT",
+				AccessControlAllowHeaders:     "content-type",
+				AccessControlAllowCredentials: "true",
+				AccessControlMaxAge:           "3600",
+			},
+		},
+		{
+			name:   "preflight unregistered method",
+			method: http.MethodOptions,
+			header: map[string]string{
+				Origin:                     "https://app.example.com",
+				AccessControlRequestMethod: http.MethodDelete,
+			},
+			statusCode: http.StatusForbidden,
+			expected:   map[string]string{AccessControlAllowOrigin: ""},
+		},
+		{
+			name:   "preflight origin not allowed",
+			method: http.MethodOptions,
+			header: map[string]string{
+				Origin:                     "https://example.org",
+				AccessControlRequestMethod: http.MethodGet,
+			},
+			statusCode: http.StatusForbidden,
+			expected:   map[string]string{AccessControlAllowOrigin: ""},
+		},
+		{
+			name:       "actual request",
+			method:     http.MethodGet,
+			header:     map[string]string{Origin: "https://app.example.com"},
+			statusCode: http.StatusOK,
+			expected: map[string]string{
+				AccessControlAllowOrigin:      "https://app.example.com",
+				AccessControlExposeHeaders:    ETag,
+				AccessControlAllowCredentials: "true",
+				Vary:                          Origin,
+			},
+		},
+		{
+			name:       "actual request origin not allowed",
+			method:     http.MethodGet,
+			header:     map[string]string{Origin: "https://example.org"},
+			statusCode: http.StatusOK,
+			expected:   map[string]string{AccessControlAllowOrigin: "", Vary: Origin},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/todo", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, r)
+
+			if w.Code != tt.statusCode {
+				t.Errorf("status = %d; expected %d", w.Code, tt.statusCode)
+			}
+			for k, v := range tt.expected {
+				if got := strings.Join(w.Header().Values(k), ", "); !strings.HasPrefix(got, v) || (len(v) == 0 && len(got) > 0) {
+					t.Errorf("%s = %s; expected %s", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSHandler(t *testing.T) {
+	handler := Post(func(r *Request[EmptyBody], w *Response[EmptyBody]) {}, WithCORS(CORSConfig{AllowedOrigins: []string{"*"}}))
+
+	r := httptest.NewRequest(http.MethodOptions, "/", nil)
+	r.Header.Set(Origin, "https://app.example.com")
+	r.Header.Set(AccessControlRequestMethod, http.MethodPost)
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	if w.Code != http.StatusNoContent || w.Header().Get(AccessControlAllowOrigin) != "*" ||
+		w.Header().Get(AccessControlAllowMethods) != http.MethodPost {
+		t.Errorf("preflight = %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSCredentialsAnyOrigin(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected WithCORS to panic for the * origin with credentials")
+		}
+	}()
+	WithCORS(CORSConfig{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true})
+}
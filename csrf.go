@@ -0,0 +1,218 @@
+package glhf
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	// CSRFTokenHeader header constant.
+	CSRFTokenHeader = "X-CSRF-Token"
+	// SecFetchSite header constant.
+	SecFetchSite = "Sec-Fetch-Site"
+	// ContentForm header value for URL encoded forms.
+	ContentForm = "application/x-www-form-urlencoded"
+
+	defaultCSRFCookie = "csrf_token"
+	defaultCSRFField  = "csrf_token"
+)
+
+// CSRFConfig configures Cross-Site Request Forgery protection.
+type CSRFConfig struct {
+	// CookieName is the name of the double-submit cookie. Defaults to csrf_token.
+	CookieName string
+	// HeaderName is the request header carrying the token. Defaults to X-CSRF-Token.
+	HeaderName string
+	// FormField is the field of URL encoded form bodies carrying the token. Defaults to csrf_token.
+	FormField string
+	// TrustedOrigins are origins, other than the request's own, allowed to make unsafe requests.
+	// Patterns are matched with path.Match, i.e "https://*.example.com".
+	TrustedOrigins []string
+	// Secret signs tokens. With a double-submit cookie, the signature prevents cookies planted by other
+	// subdomains from being accepted. Required if Session is set.
+	Secret []byte
+	// Session returns the session id of the request, i.e from a session cookie. If set, tokens are synchronizer
+	// tokens derived from the session and no cookie is set.
+	Session func(r *http.Request) string
+}
+
+type csrfTokenKey struct{}
+
+// ContextCSRFToken returns the CSRF token stored in ctx, or an empty string if CSRF protection is not enabled.
+func ContextCSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// csrfGuard enforces a CSRFConfig.
+type csrfGuard struct {
+	config      CSRFConfig
+	maxBodySize int64
+}
+
+func newCSRFGuard(config CSRFConfig, maxBodySize int64) *csrfGuard {
+	if len(config.CookieName) == 0 {
+		config.CookieName = defaultCSRFCookie
+	}
+	if len(config.HeaderName) == 0 {
+		config.HeaderName = CSRFTokenHeader
+	}
+	if len(config.FormField) == 0 {
+		config.FormField = defaultCSRFField
+	}
+	if config.Session != nil && len(config.Secret) == 0 {
+		panic("glhf: synchronizer csrf tokens require a secret")
+	}
+	return &csrfGuard{config: config, maxBodySize: maxBodySize}
+}
+
+// protect stores the request's token in its context, issuing a cookie if needed. Unsafe requests fail with 403
+// if they are cross-origin or do not carry the token. Requests authenticated by a JWT authenticator are exempt.
+func (g *csrfGuard) protect(w http.ResponseWriter, r *http.Request) (*http.Request, *errorResponse) {
+	token, ok := g.token(r)
+	// synchronizer tokens require a session, the request fails below if it is unsafe
+	if !ok && g.config.Session == nil {
+		token = g.newToken()
+		http.SetCookie(w, &http.Cookie{
+			Name:     g.config.CookieName,
+			Value:    token,
+			Path:     "/",
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	r = r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, token))
+
+	if isSafe(r.Method) || r.Method == http.MethodOptions || isBearer(r) {
+		return r, nil
+	}
+
+	if !g.sameOrigin(r) {
+		return r, &errorResponse{
+			Code:    http.StatusForbidden,
+			Message: "cross-origin request forbidden",
+		}
+	}
+
+	submitted := r.Header.Get(g.config.HeaderName)
+	if len(submitted) == 0 {
+		submitted = g.formToken(r)
+	}
+	// a token issued by this request was not known to the client
+	if !ok || len(submitted) == 0 || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+		return r, &errorResponse{
+			Code:    http.StatusForbidden,
+			Message: "missing or invalid csrf token",
+		}
+	}
+	return r, nil
+}
+
+// token returns the request's current token, ok is false if it has none or its cookie is invalid.
+func (g *csrfGuard) token(r *http.Request) (string, bool) {
+	if g.config.Session != nil {
+		session := g.config.Session(r)
+		if len(session) == 0 {
+			return "", false
+		}
+		return g.sign(session), true
+	}
+
+	c, err := r.Cookie(g.config.CookieName)
+	if err != nil || len(c.Value) == 0 {
+		return "", false
+	}
+	if len(g.config.Secret) > 0 {
+		nonce, _, _ := strings.Cut(c.Value, ".")
+		if !hmac.Equal([]byte(c.Value), []byte(nonce+"."+g.sign(nonce))) {
+			return "", false
+		}
+	}
+	return c.Value, true
+}
+
+// newToken returns a random token, signed if a secret is configured.
+func (g *csrfGuard) newToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+	if len(g.config.Secret) > 0 {
+		return nonce + "." + g.sign(nonce)
+	}
+	return nonce
+}
+
+func (g *csrfGuard) sign(value string) string {
+	mac := hmac.New(sha256.New, g.config.Secret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sameOrigin checks the Sec-Fetch-Site and Origin headers of the request. Requests without either header,
+// i.e from non-browser clients, rely on the token.
+func (g *csrfGuard) sameOrigin(r *http.Request) bool {
+	switch r.Header.Get(SecFetchSite) {
+	case "same-origin", "none":
+		return true
+	}
+
+	origin := r.Header.Get(Origin)
+	if len(origin) == 0 {
+		return len(r.Header.Get(SecFetchSite)) == 0
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	for _, pattern := range g.config.TrustedOrigins {
+		if ok, _ := path.Match(pattern, origin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// formToken returns the token field of a URL encoded form body. The body is restored for the handler.
+func (g *csrfGuard) formToken(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(ContentType))
+	if mediaType != ContentForm || r.Body == nil || len(r.Header.Get(ContentEncoding)) > 0 {
+		return ""
+	}
+
+	body := r.Body
+	reader := io.Reader(body)
+	if g.maxBodySize > 0 {
+		reader = io.LimitReader(body, g.maxBodySize+1)
+	}
+	b, err := io.ReadAll(reader)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), body), body}
+	if err != nil {
+		return ""
+	}
+
+	form, err := url.ParseQuery(string(b))
+	if err != nil {
+		return ""
+	}
+	return form.Get(g.config.FormField)
+}
+
+// isBearer reports whether the request was authenticated by a JWT authenticator, bearer tokens are never sent by
+// browsers implicitly. An Authorization header alone does not exempt a request, anyone can send one.
+func isBearer(r *http.Request) bool {
+	bearer, _ := r.Context().Value(bearerKey{}).(bool)
+	return bearer
+}
@@ -0,0 +1,180 @@
+package glhf
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	handler := Post(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		w.SetStatus(http.StatusNoContent)
+	}, WithCSRF(CSRFConfig{TrustedOrigins: []string{"https://*.example.com"}}))
+
+	// a safe request issues the cookie
+	get := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		if len(r.CSRFToken()) == 0 {
+			t.Error("expected a csrf token")
+		}
+	}, WithCSRF(CSRFConfig{}))
+	w := httptest.NewRecorder()
+	get(w, httptest.NewRequest(http.MethodGet, "http://api.test/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != defaultCSRFCookie {
+		t.Fatalf("cookies = %v; expected a %s cookie", cookies, defaultCSRFCookie)
+	}
+	token := cookies[0].Value
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		cookie bool
+		status int
+	}{
+		{name: "header token", cookie: true, header: map[string]string{CSRFTokenHeader: token}, status: http.StatusNoContent},
+		{name: "missing token", cookie: true, status: http.StatusForbidden},
+		{name: "wrong token", cookie: true, header: map[string]string{CSRFTokenHeader: "wrong"}, status: http.StatusForbidden},
+		{name: "missing cookie", header: map[string]string{CSRFTokenHeader: token}, status: http.StatusForbidden},
+		{name: "same origin", cookie: true, header: map[string]string{CSRFTokenHeader: token, Origin: "http://api.test"}, status: http.StatusNoContent},
+		{name: "trusted origin", cookie: true, header: map[string]string{CSRFTokenHeader: token, Origin: "https://app.example.com"}, status: http.StatusNoContent},
+		{name: "cross origin", cookie: true, header: map[string]string{CSRFTokenHeader: token, Origin: "https://evil.test"}, status: http.StatusForbidden},
+		{name: "cross site fetch", cookie: true, header: map[string]string{CSRFTokenHeader: token, SecFetchSite: "cross-site"}, status: http.StatusForbidden},
+		{name: "same origin fetch", cookie: true, header: map[string]string{CSRFTokenHeader: token, SecFetchSite: "same-origin"}, status: http.StatusNoContent},
+		{name: "forged bearer", header: map[string]string{Authorization: "Bearer token", Origin: "https://evil.test"}, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://api.test/", strings.NewReader("{}"))
+			r.Header.Set(ContentType, ContentJSON)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if tt.cookie {
+				r.AddCookie(&http.Cookie{Name: defaultCSRFCookie, Value: token})
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.status {
+				t.Errorf("status = %d; expected %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestCSRFBearer(t *testing.T) {
+	secret := []byte("secret")
+	jwt := NewJWTAuthenticator(JWTConfig{Keys: []JWK{{Algorithm: "HS256", Key: secret}}})
+	basic := NewBasicAuthenticator("glhf", BasicUsers(map[string]string{"alice": "secret"}))
+	handler := Post(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		w.SetStatus(http.StatusNoContent)
+	}, WithAuth(jwt, basic), WithCSRF(CSRFConfig{}))
+
+	token := signJWT(t, "HS256", "", secret, map[string]any{"sub": "alice"})
+	tests := []struct {
+		name   string
+		auth   func(r *http.Request)
+		status int
+	}{
+		{name: "jwt", auth: func(r *http.Request) { r.Header.Set(Authorization, "Bearer "+token) }, status: http.StatusNoContent},
+		{name: "basic", auth: func(r *http.Request) { r.SetBasicAuth("alice", "secret") }, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://api.test/", nil)
+			r.Header.Set(Origin, "https://evil.test")
+			tt.auth(r)
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.status {
+				t.Errorf("status = %d; expected %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestCSRFFormToken(t *testing.T) {
+	g := newCSRFGuard(CSRFConfig{}, defaultMaxBodySize)
+	body := "name=glhf&csrf_token=token"
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set(ContentType, ContentForm)
+	r.AddCookie(&http.Cookie{Name: defaultCSRFCookie, Value: "token"})
+	r, errResp := g.protect(httptest.NewRecorder(), r)
+	if errResp != nil {
+		t.Fatalf("protect failed: %s", errResp.Message)
+	}
+
+	// the body is restored for the handler
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != body {
+		t.Errorf("body = %q; expected %q", b, body)
+	}
+}
+
+func TestCSRFSigned(t *testing.T) {
+	g := newCSRFGuard(CSRFConfig{Secret: []byte("secret")}, 0)
+	token := g.newToken()
+
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{name: "signed", value: token, ok: true},
+		{name: "unsigned", value: "planted", ok: false},
+		{name: "forged", value: "planted.signature", ok: false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.AddCookie(&http.Cookie{Name: defaultCSRFCookie, Value: tt.value})
+		if _, ok := g.token(r); ok != tt.ok {
+			t.Errorf("%s: ok = %t; expected %t", tt.name, ok, tt.ok)
+		}
+	}
+}
+
+func TestCSRFSession(t *testing.T) {
+	config := CSRFConfig{
+		Secret: []byte("secret"),
+		Session: func(r *http.Request) string {
+			c, err := r.Cookie("session")
+			if err != nil {
+				return ""
+			}
+			return c.Value
+		},
+	}
+	token := newCSRFGuard(config, 0).sign("abc")
+
+	handler := Post(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		w.SetStatus(http.StatusNoContent)
+	}, WithCSRF(config))
+
+	for _, session := range []string{"abc", "xyz"} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+		r.Header.Set(ContentType, ContentJSON)
+		r.AddCookie(&http.Cookie{Name: "session", Value: session})
+		r.Header.Set(CSRFTokenHeader, token)
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		expected := http.StatusNoContent
+		if session != "abc" {
+			expected = http.StatusForbidden
+		}
+		if w.Code != expected {
+			t.Errorf("session %s: status = %d; expected %d", session, w.Code, expected)
+		}
+		if len(w.Result().Cookies()) > 0 {
+			t.Errorf("session %s: synchronizer tokens must not set a cookie", session)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package glhf
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// UnknownFields selects how request body fields unknown to the body type are handled.
+type UnknownFields int
+
+const (
+	// UnknownFieldsDefault keeps the codec's behaviour, encoding/json discards unknown fields and proto keeps them
+	// as unknown fields of the message.
+	UnknownFieldsDefault UnknownFields = iota
+	// UnknownFieldsDiscard drops unknown fields.
+	UnknownFieldsDiscard
+	// UnknownFieldsReject fails the request with 400 Bad Request.
+	UnknownFieldsReject
+)
+
+// DecodePolicy configures how request bodies are decoded, see WithDecodePolicy.
+type DecodePolicy struct {
+	// UnknownFields applies to JSON and proto bodies.
+	UnknownFields UnknownFields
+	// UseNumber decodes JSON numbers into interface values as json.Number instead of float64.
+	UseNumber bool
+	// CaseSensitive matches JSON object keys to struct fields exactly, encoding/json matches them case-insensitively.
+	// Keys that only differ in case from a field are unknown fields.
+	CaseSensitive bool
+	// AllowEmptyBody decodes a missing or empty request body of handlers that require a body as the zero value of
+	// the body type instead of failing with 400.
+	AllowEmptyBody bool
+}
+
+var (
+	// StrictDecoding rejects unknown fields and matches JSON keys case-sensitively.
+	StrictDecoding = DecodePolicy{UnknownFields: UnknownFieldsReject, CaseSensitive: true}
+	// LenientDecoding discards unknown fields and allows empty bodies.
+	LenientDecoding = DecodePolicy{UnknownFields: UnknownFieldsDiscard, AllowEmptyBody: true}
+)
+
+// unmarshalBody decodes data of mediaType into v with codec. The built-in JSON, JSON Merge Patch and proto codecs
+// apply the decode policy, and the hardening checks apply to every JSON media type, i.e application/merge-patch+json.
+// Other codecs are used as is. Hardening rejects unknown fields unless the decode policy sets how they are handled.
+func unmarshalBody(mediaType string, codec Codec, data []byte, v any, opts *opts) error {
+	policy := opts.decodePolicy
+	if opts.hardening != nil && policy.UnknownFields == UnknownFieldsDefault {
+		policy.UnknownFields = UnknownFieldsReject
+	}
+
+	_, isJSON := codec.(jsonCodec)
+	if opts.hardening != nil && (isJSON || isJSONMediaType(mediaType)) {
+		if err := checkJSON(data, opts.hardening.MaxDepth); err != nil {
+			return err
+		}
+	}
+
+	switch c := codec.(type) {
+	case jsonCodec:
+		return unmarshalJSON(data, v, policy)
+	case patchCodec:
+		// a merge patch decoded into a body other than PatchDocument is a JSON document of the body type
+		if _, ok := v.(*PatchDocument); !ok && c.mergePatch {
+			return unmarshalJSON(data, v, policy)
+		}
+	case protoCodec:
+		return unmarshalProto(data, v, policy)
+	}
+	return codec.Unmarshal(data, v)
+}
+
+// isJSONMediaType reports whether mediaType is application/json or uses the +json structured syntax suffix.
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == ContentJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+// unmarshalJSON decodes a single JSON value into v according to policy.
+func unmarshalJSON(data []byte, v any, policy DecodePolicy) error {
+	reject := policy.UnknownFields == UnknownFieldsReject
+	if policy.CaseSensitive {
+		var err error
+		if data, err = matchJSONCase(data, reflect.TypeOf(v), reject); err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if reject {
+		dec.DisallowUnknownFields()
+	}
+	if policy.UseNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(v); err != nil {
+		// encoding/json does not export the unknown field error
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("%w: %s", ErrUnknownField, field)
+		}
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// matchJSONCase returns data without the object keys that do not exactly match a field of t. If reject is true
+// such keys fail with ErrUnknownField instead.
+func matchJSONCase(data []byte, t reflect.Type, reject bool) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if err := filterJSONKeys(value, t, reject); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// filterJSONKeys walks value, a decoded JSON value, along t.
+func filterJSONKeys(value any, t reflect.Type, reject bool) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// custom decoding, keys are interpreted by the type
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		fields := jsonFields(t)
+		for key, v := range obj {
+			ft, ok := fields[key]
+			if !ok {
+				if reject {
+					return fmt.Errorf("%w: %q", ErrUnknownField, key)
+				}
+				delete(obj, key)
+				continue
+			}
+			if err := filterJSONKeys(v, ft, reject); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if obj, ok := value.(map[string]any); ok {
+			for _, v := range obj {
+				if err := filterJSONKeys(v, t.Elem(), reject); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if list, ok := value.([]any); ok {
+			for _, v := range list {
+				if err := filterJSONKeys(v, t.Elem(), reject); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// jsonFields returns the JSON names of the fields of struct type t, including promoted fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && len(name) == 0 {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range jsonFields(ft) {
+					if _, ok := fields[k]; !ok {
+						fields[k] = v
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// unmarshalProto decodes a proto message into v according to policy.
+func unmarshalProto(data []byte, v any, policy DecodePolicy) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return ErrProto
+	}
+
+	o := proto.UnmarshalOptions{DiscardUnknown: policy.UnknownFields == UnknownFieldsDiscard}
+	if err := o.Unmarshal(data, msg); err != nil {
+		return err
+	}
+	if policy.UnknownFields == UnknownFieldsReject && hasUnknownFields(msg.ProtoReflect()) {
+		return ErrUnknownField
+	}
+	return nil
+}
+
+// hasUnknownFields reports whether m or any message nested in m has unknown fields.
+func hasUnknownFields(m protoreflect.Message) bool {
+	if len(m.GetUnknown()) > 0 {
+		return true
+	}
+
+	found := false
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					found = hasUnknownFields(mv.Message())
+					return !found
+				})
+			}
+		case fd.IsList():
+			if fd.Message() != nil {
+				for i := 0; i < v.List().Len() && !found; i++ {
+					found = hasUnknownFields(v.List().Get(i).Message())
+				}
+			}
+		case fd.Message() != nil:
+			found = hasUnknownFields(v.Message())
+		}
+		return !found
+	})
+	return found
+}
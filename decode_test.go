@@ -0,0 +1,204 @@
+package glhf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestUnmarshalJSON(t *testing.T) {
+	type base struct {
+		ID string `json:"id"`
+	}
+	type item struct {
+		base
+		Name  string `json:"name"`
+		Tags  []struct{ Key string }
+		Extra map[string]any `json:"extra"`
+	}
+
+	tests := []struct {
+		name     string
+		policy   DecodePolicy
+		body     string
+		expected item
+		err      error
+	}{
+		{name: "default unknown", body: `{"name":"a","admin":true}`, expected: item{Name: "a"}},
+		{name: "discard unknown", policy: DecodePolicy{UnknownFields: UnknownFieldsDiscard}, body: `{"name":"a","admin":true}`, expected: item{Name: "a"}},
+		{name: "reject unknown", policy: DecodePolicy{UnknownFields: UnknownFieldsReject}, body: `{"name":"a","admin":true}`, err: ErrUnknownField},
+		{name: "case insensitive", body: `{"NAME":"a","ID":"1"}`, expected: item{base: base{ID: "1"}, Name: "a"}},
+		{name: "case sensitive", policy: DecodePolicy{CaseSensitive: true}, body: `{"NAME":"a","id":"1","tags":[{"Key":"k","key":"x"}]}`,
+			expected: item{base: base{ID: "1"}}},
+		{name: "case sensitive nested", policy: DecodePolicy{CaseSensitive: true}, body: `{"Tags":[{"Key":"k","key":"x"}]}`,
+			expected: item{Tags: []struct{ Key string }{{Key: "k"}}}},
+		{name: "case sensitive reject", policy: StrictDecoding, body: `{"Name":"a"}`, err: ErrUnknownField},
+		{name: "use number", policy: DecodePolicy{UseNumber: true}, body: `{"extra":{"n":12345678901234567890}}`,
+			expected: item{Extra: map[string]any{"n": json.Number("12345678901234567890")}}},
+		{name: "float", body: `{"extra":{"n":1}}`, expected: item{Extra: map[string]any{"n": float64(1)}}},
+		{name: "trailing data", body: `{"name":"a"} {}`, err: ErrTrailingData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v item
+			err := unmarshalJSON([]byte(tt.body), &v, tt.policy)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v; expected %v", err, tt.err)
+			}
+			if tt.err == nil && !reflect.DeepEqual(v, tt.expected) {
+				t.Errorf("body = %+v; expected %+v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnmarshalProto(t *testing.T) {
+	b, err := proto.Marshal(wrapperspb.String("glhf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// field 2 is unknown to StringValue
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+
+	tests := []struct {
+		unknownFields UnknownFields
+		unknown       bool
+		err           error
+	}{
+		{unknownFields: UnknownFieldsDefault, unknown: true},
+		{unknownFields: UnknownFieldsDiscard, unknown: false},
+		{unknownFields: UnknownFieldsReject, err: ErrUnknownField},
+	}
+
+	for _, tt := range tests {
+		msg := &wrapperspb.StringValue{}
+		err := unmarshalProto(b, msg, DecodePolicy{UnknownFields: tt.unknownFields})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%d: err = %v; expected %v", tt.unknownFields, err, tt.err)
+			continue
+		}
+		if tt.err == nil && (msg.Value != "glhf" || len(msg.ProtoReflect().GetUnknown()) > 0 != tt.unknown) {
+			t.Errorf("%d: message = %v; expected unknown fields %t", tt.unknownFields, msg, tt.unknown)
+		}
+	}
+}
+
+func TestBodyMode(t *testing.T) {
+	type test struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		mode    *BodyMode
+		body    string
+		chunked bool
+		status  int
+		hasBody bool
+	}{
+		{name: "delete without body", method: http.MethodDelete, status: http.StatusOK},
+		{name: "delete with body", method: http.MethodDelete, body: `{"name":"glhf"}`, status: http.StatusOK, hasBody: true},
+		{name: "post chunked body", method: http.MethodPost, body: `{"name":"glhf"}`, chunked: true, status: http.StatusOK, hasBody: true},
+		{name: "post chunked without body", method: http.MethodPost, chunked: true, status: http.StatusOK},
+		{name: "patch without body", method: http.MethodPatch, status: http.StatusBadRequest},
+		{name: "required", method: http.MethodDelete, mode: ptr(BodyRequired), status: http.StatusBadRequest},
+		{name: "forbidden", method: http.MethodDelete, mode: ptr(BodyForbidden), body: `{"name":"glhf"}`, status: http.StatusBadRequest},
+		{name: "forbidden without body", method: http.MethodDelete, mode: ptr(BodyForbidden), status: http.StatusOK},
+		{name: "ignored", method: http.MethodPost, mode: ptr(BodyIgnored), body: `{`, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options []Options
+			if tt.mode != nil {
+				options = append(options, WithBody(*tt.mode))
+			}
+			fn := func(r *Request[test], w *Response[test]) {
+				if r.HasBody() != tt.hasBody || (r.Body() != nil) != tt.hasBody {
+					t.Errorf("HasBody = %t, Body = %v; expected body %t", r.HasBody(), r.Body(), tt.hasBody)
+				}
+			}
+			var handler http.HandlerFunc
+			switch tt.method {
+			case http.MethodDelete:
+				handler = Delete(fn, options...)
+			case http.MethodPatch:
+				handler = Patch(fn, options...)
+			default:
+				handler = Post(fn, options...)
+			}
+
+			r := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			if len(tt.body) > 0 {
+				r.Header.Set(ContentType, ContentJSON)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.status {
+				t.Errorf("status = %d; expected %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestDecodeRequest(t *testing.T) {
+	type test struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name        string
+		options     []Options
+		body        string
+		contentType string
+		status      int
+	}{
+		{name: "valid", body: `{"name":"glhf"}`, contentType: ContentJSON, status: http.StatusOK},
+		{name: "no body", contentType: ContentJSON, status: http.StatusOK},
+		{name: "empty body", options: []Options{WithBody(BodyRequired)}, contentType: ContentJSON, status: http.StatusBadRequest},
+		{name: "empty body allowed", options: []Options{WithBody(BodyRequired), WithDecodePolicy(LenientDecoding)}, contentType: ContentJSON, status: http.StatusOK},
+		{name: "empty body without content-type", options: []Options{WithBody(BodyRequired), WithDecodePolicy(LenientDecoding)}, status: http.StatusOK},
+		{name: "missing content-type", body: `{"name":"glhf"}`, status: http.StatusUnsupportedMediaType},
+		{name: "unsupported content-type", body: `name=glhf`, contentType: "text/csv", status: http.StatusUnsupportedMediaType},
+		{name: "malformed", body: `{`, contentType: ContentJSON, status: http.StatusBadRequest},
+		{name: "unknown field", options: []Options{WithDecodePolicy(StrictDecoding)}, body: `{"name":"glhf","admin":true}`,
+			contentType: ContentJSON, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Post(func(r *Request[test], w *Response[test]) {
+				w.SetBody(r.Body())
+			}, tt.options...)
+
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(tt.body)))
+			if len(tt.contentType) > 0 {
+				r.Header.Set(ContentType, tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.status {
+				t.Errorf("status = %d; expected %d: %s", w.Code, tt.status, strings.TrimSpace(w.Body.String()))
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package glhf
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultMaxBodySize is the maximum size, in bytes, of a decoded request body.
+const defaultMaxBodySize = 32 << 20
+
+// decompressors maps a content-coding to a constructor of a reader that decodes it. limit is the maximum decoded
+// body size, or 0 if unlimited.
+var decompressors = map[string]func(r io.Reader, limit int64) (io.ReadCloser, error){
+	EncodingGzip: func(r io.Reader, _ int64) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	EncodingDeflate: func(r io.Reader, _ int64) (io.ReadCloser, error) {
+		return zlib.NewReader(r)
+	},
+	EncodingZstd: func(r io.Reader, limit int64) (io.ReadCloser, error) {
+		options := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+		if limit > 0 {
+			// a frame's window is allocated up front, before any output reaches the size limit
+			window := uint64(limit)
+			if window < zstd.MinWindowSize {
+				window = zstd.MinWindowSize
+			}
+			if window > zstd.MaxWindowSize {
+				window = zstd.MaxWindowSize
+			}
+			options = append(options, zstd.WithDecoderMaxMemory(uint64(limit)+1), zstd.WithDecoderMaxWindow(window))
+		}
+		zr, err := zstd.NewReader(r, options...)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	},
+}
+
+// readBody reads the request body, removing any content-codings listed in the Content-Encoding header.
+// The size limit applies to the decoded body so compressed bodies can not be used to exhaust memory.
+func readBody(r *http.Request, limit int64) ([]byte, *errorResponse) {
+	var codings []string
+	for _, v := range r.Header.Values(ContentEncoding) {
+		for _, coding := range strings.Split(v, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if len(coding) > 0 && coding != "identity" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+
+	var body io.Reader = r.Body
+	// codings are listed in the order they were applied, decode in reverse
+	for i := len(codings) - 1; i >= 0; i-- {
+		newReader, ok := decompressors[codings[i]]
+		if !ok {
+			return nil, &errorResponse{
+				Code:    http.StatusUnsupportedMediaType,
+				Message: "unsupported content-encoding " + codings[i],
+			}
+		}
+
+		zr, err := newReader(body, limit)
+		if err != nil {
+			return nil, &errorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "failed to decode request body with content-encoding " + codings[i],
+			}
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	if limit > 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		if len(codings) > 0 {
+			return nil, &errorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "failed to decode request body with content-encoding " + strings.Join(codings, ", "),
+			}
+		}
+		return nil, &errorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "failed to read request body",
+		}
+	}
+
+	if limit > 0 && int64(len(b)) > limit {
+		return nil, &errorResponse{
+			Code:    http.StatusRequestEntityTooLarge,
+			Message: "request body exceeds the maximum size",
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,67 @@
+package glhf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecompressRequest(t *testing.T) {
+	type message struct {
+		Text string `json:"text"`
+	}
+
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(s))
+		zw.Close()
+		return buf.Bytes()
+	}
+	// zstdFrame returns a frame with a single raw block that declares a window of 1 << windowLog bytes
+	zstdFrame := func(s string, windowLog int) []byte {
+		b := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, byte(windowLog-10) << 3}
+		header := uint32(len(s))<<3 | 1
+		b = append(b, byte(header), byte(header>>8), byte(header>>16))
+		return append(b, s...)
+	}
+
+	testCases := []struct {
+		name     string
+		encoding string
+		body     []byte
+		expected int
+	}{
+		{"identity", "", []byte(`{"text":"glhf"}`), http.StatusOK},
+		{"gzip", EncodingGzip, gzipped(`{"text":"glhf"}`), http.StatusOK},
+		{"zstd", EncodingZstd, zstdFrame(`{"text":"glhf"}`, 10), http.StatusOK},
+		// the frame's window exceeds the body size limit, it is rejected before the window is allocated
+		{"zstd window", EncodingZstd, zstdFrame(`{"text":"glhf"}`, 23), http.StatusBadRequest},
+		{"corrupt", EncodingGzip, []byte(`{"text":"glhf"}`), http.StatusBadRequest},
+		{"unsupported", "compress", []byte(`{"text":"glhf"}`), http.StatusUnsupportedMediaType},
+		{"too large", EncodingGzip, gzipped(`{"text":"` + strings.Repeat("a", 2048) + `"}`), http.StatusRequestEntityTooLarge},
+	}
+
+	handler := Post(func(r *Request[message], w *Response[message]) {
+		w.SetBody(r.Body())
+	}, WithMaxBodySize(1024))
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(testCase.body))
+			r.Header.Set(ContentType, ContentJSON)
+			if len(testCase.encoding) > 0 {
+				r.Header.Set(ContentEncoding, testCase.encoding)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != testCase.expected {
+				t.Errorf("status = %d; expected %d", w.Code, testCase.expected)
+			}
+		})
+	}
+}
@@ -6,13 +6,57 @@ var (
 	ErrProto                   = errors.New("value can not be used as proto message, invalid type")
 	ErrUnsupportedResponseType = errors.New("response type unsupported")
 	ErrUnsupportedRequestType  = errors.New("request type unsupported")
+	ErrPatchDocument           = errors.New("value can not be used as a patch document, invalid type")
+	ErrPatchTest               = errors.New("json patch test operation failed")
+	ErrPatchPath               = errors.New("json patch path does not exist")
+	ErrPatchOp                 = errors.New("json patch operation unsupported")
+	ErrPatchOperation          = errors.New("json patch operation is invalid")
+	ErrNoCredentials           = errors.New("request has no credentials")
+	ErrInvalidCredentials      = errors.New("credentials are invalid")
+	ErrInvalidKey              = errors.New("json web key is invalid or unsupported")
+	ErrInvalidToken            = errors.New("token is malformed")
+	ErrInvalidSignature        = errors.New("token signature is invalid")
+	ErrTokenExpired            = errors.New("token is expired")
+	ErrTokenNotValidYet        = errors.New("token is not valid yet")
+	ErrInvalidIssuer           = errors.New("token issuer is invalid")
+	ErrInvalidAudience         = errors.New("token audience is invalid")
+	ErrMissingScope            = errors.New("principal is missing a required scope")
+	ErrMissingRole             = errors.New("principal is missing a required role")
+	ErrDuplicateKey            = errors.New("json object has a duplicate key")
+	ErrUnknownField            = errors.New("json object has an unknown field")
+	ErrTrailingData            = errors.New("json value is followed by trailing data")
+	ErrMaxDepth                = errors.New("json value exceeds the maximum nesting depth")
+	ErrMissingSignature        = errors.New("message has no matching signature")
+	ErrMessageSignature        = errors.New("message signature is invalid")
+	ErrSignatureExpired        = errors.New("message signature is expired")
+	ErrMissingComponent        = errors.New("message signature component is missing")
+	ErrUnknownKeyID            = errors.New("message signature key is unknown")
+	ErrUnsupportedAlgorithm    = errors.New("message signature algorithm is unsupported")
+	ErrContentDigest           = errors.New("content digest does not match the body")
 )
 
-// errorResponse is a optional response that can be
-// used to debug GLHF
+// errorResponse is an error glhf responds with.
 type errorResponse struct {
 	// Code is the HTTP Status code
 	Code int `json:"code"`
 	// Message is a developer-facing human-readable error message.
 	Message string `json:"message"`
 }
+
+// problem is the RFC 9457 problem details body of an error response.
+type problem struct {
+	// Type is about:blank, problems are described by their status code.
+	Type string `json:"type"`
+	// Title is the status text of Status.
+	Title string `json:"title"`
+	// Status is the HTTP status code.
+	Status int `json:"status"`
+	// Detail is the error message, set if verbose is enabled.
+	Detail string `json:"detail,omitempty"`
+	// RequestID identifies the request in logs, set if request IDs are enabled.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (e *errorResponse) Error() string {
+	return e.Message
+}
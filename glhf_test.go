@@ -1,7 +1,11 @@
 package glhf
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +32,45 @@ func TestValidateStatusCode(t *testing.T) {
 		})
 	}
 }
+
+func TestProblemDetails(t *testing.T) {
+	type todo struct {
+		Title string `json:"title"`
+	}
+
+	tests := []struct {
+		name     string
+		options  []Options
+		expected problem
+	}{
+		{name: "default", options: []Options{WithRequestID(true)}, expected: problem{
+			Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest, RequestID: "req-1",
+		}},
+		{name: "verbose", options: []Options{WithVerbose(true)}, expected: problem{
+			Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest, Detail: "failed to unmarshal request",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Post(func(r *Request[todo], w *Response[todo]) {}, tt.options...)
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{`))
+			r.Header.Set(ContentType, ContentJSON)
+			r.Header.Set(RequestID, "req-1")
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Header().Get(ContentType) != ContentProblemJSON {
+				t.Errorf("%s = %s; expected %s", ContentType, w.Header().Get(ContentType), ContentProblemJSON)
+			}
+			p := problem{}
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Type != tt.expected.Type || p.Title != tt.expected.Title || p.Status != tt.expected.Status ||
+				p.RequestID != tt.expected.RequestID || !strings.HasPrefix(p.Detail, tt.expected.Detail) {
+				t.Errorf("problem = %+v; expected %+v", p, tt.expected)
+			}
+		})
+	}
+}
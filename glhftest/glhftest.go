@@ -0,0 +1,71 @@
+// Package glhftest provides utilities for testing glhf handlers.
+package glhftest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VauntDev/glhf"
+)
+
+// PolicyCase is a row of a policy table, see CheckPolicies.
+type PolicyCase[I glhf.Body] struct {
+	// Name describes the case.
+	Name string
+	// Principal is the authenticated principal of the request, nil if the request is not authenticated.
+	Principal glhf.Principal
+	// Body is the request body, sent as JSON. A nil body sends the zero value of I.
+	Body *I
+	// Allowed is true if the request must reach the handler.
+	Allowed bool
+}
+
+// CheckPolicies sends a request for every case to a Post handler built with options, i.e glhf.WithScopes,
+// glhf.WithRoles and glhf.WithPolicy, and fails t if the request is forbidden when it should be allowed or
+// reaches the handler when it should be forbidden. Denied requests must fail with 403, or 401 without a principal.
+func CheckPolicies[I glhf.Body](t *testing.T, options []glhf.Options, cases []PolicyCase[I]) {
+	t.Helper()
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Helper()
+
+			called := false
+			handler := glhf.Post(func(r *glhf.Request[I], w *glhf.Response[glhf.EmptyBody]) {
+				called = true
+				w.SetStatus(http.StatusNoContent)
+			}, options...)
+
+			requestBody := tc.Body
+			if requestBody == nil {
+				requestBody = new(I)
+			}
+			body, err := json.Marshal(requestBody)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			r.Header.Set(glhf.ContentType, glhf.ContentJSON)
+			if tc.Principal != nil {
+				r = r.WithContext(glhf.ContextWithPrincipal(r.Context(), tc.Principal))
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			switch {
+			case tc.Allowed && !called:
+				t.Errorf("request was not allowed, status %d: %s", w.Code, w.Body.String())
+			case !tc.Allowed && called:
+				t.Errorf("request was allowed")
+			case !tc.Allowed && tc.Principal == nil && w.Code != http.StatusUnauthorized:
+				t.Errorf("status = %d; expected %d", w.Code, http.StatusUnauthorized)
+			case !tc.Allowed && tc.Principal != nil && w.Code != http.StatusForbidden:
+				t.Errorf("status = %d; expected %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
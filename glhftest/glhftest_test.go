@@ -0,0 +1,44 @@
+package glhftest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/VauntDev/glhf"
+)
+
+type todo struct {
+	Owner string `json:"owner"`
+}
+
+func TestCheckPolicies(t *testing.T) {
+	ownTodo := func(r *glhf.Request[todo]) error {
+		if r.Body().Owner != r.Principal().Subject() {
+			return errors.New("todo is owned by another user")
+		}
+		return nil
+	}
+
+	CheckPolicies(t, []glhf.Options{glhf.WithRoles("admin", "user"), glhf.WithPolicy(ownTodo)}, []PolicyCase[todo]{
+		{
+			Name:      "owner",
+			Principal: glhf.Claims{"sub": "alice", "roles": []any{"user"}},
+			Body:      &todo{Owner: "alice"},
+			Allowed:   true,
+		},
+		{
+			Name:      "other owner",
+			Principal: glhf.Claims{"sub": "alice", "roles": []any{"user"}},
+			Body:      &todo{Owner: "bob"},
+		},
+		{
+			Name:      "missing role",
+			Principal: glhf.Claims{"sub": "alice"},
+			Body:      &todo{Owner: "alice"},
+		},
+		{
+			Name: "unauthenticated",
+			Body: &todo{Owner: "alice"},
+		},
+	})
+}
@@ -0,0 +1,569 @@
+package glhf
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// BodyMode describes how a handler treats the request body, see WithBody.
+type BodyMode int
+
+const (
+	// BodyIgnored handlers never read the request body, Request.Body returns nil.
+	BodyIgnored BodyMode = iota
+	// BodyOptional handlers decode the request body if one is sent, Request.Body returns nil otherwise.
+	BodyOptional
+	// BodyRequired handlers fail with 400 if no request body is sent.
+	BodyRequired
+	// BodyForbidden handlers fail with 400 if a request body is sent.
+	BodyForbidden
+)
+
+// newHandler builds the http.HandlerFunc shared by every HTTP method wrapper.
+func newHandler[I Body, O Body](method string, mode BodyMode, fn HandleFunc[I, O], options []Options) http.HandlerFunc {
+	opts := defaultOptions()
+	for _, opt := range options {
+		opt.Apply(opts)
+	}
+	if opts.bodyMode != nil {
+		mode = *opts.bodyMode
+	}
+
+	policies := bodyPolicies[I](opts)
+
+	rateLimiters := make([]*rateLimiter, len(opts.rateLimits))
+	for i, limit := range opts.rateLimits {
+		rateLimiters[i] = newRateLimiter(limit)
+	}
+
+	var inFlight *inFlightLimiter
+	if opts.maxInFlight > 0 {
+		inFlight = newInFlightLimiter(opts.maxInFlight)
+	}
+
+	var csrf *csrfGuard
+	if opts.csrf != nil {
+		csrf = newCSRFGuard(*opts.csrf, opts.maxBodySize)
+	}
+
+	var cache *responseCache
+	if method == http.MethodGet && opts.cacheStore != nil {
+		cache = newResponseCache(opts.cacheStore)
+	}
+	// unobserved reports no phases, used for background work that outlives the request
+	unobserved := *opts
+	unobserved.observers = nil
+
+	var idempotency *idempotencyGuard
+	if (method == http.MethodPost || method == http.MethodPatch) && opts.idempotencyStore != nil {
+		idempotency = newIdempotencyGuard(opts.idempotencyStore, opts.idempotencyTTL, opts.timeout)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if opts.requestID {
+			r = withRequestID(w, r)
+		}
+
+		rec, rw, r := startRecord(w, r, opts)
+		if rec != nil {
+			defer rec.finish(opts, rw, r)
+			w = rw
+		}
+
+		if opts.hardening != nil {
+			setSecurityHeaders(w, r, opts.hardening)
+		}
+
+		if opts.cors != nil {
+			if isPreflight(r) {
+				methods := []string{method}
+				if method == http.MethodGet {
+					methods = append(methods, http.MethodHead)
+				}
+				preflight(w, r, opts, methods)
+				return
+			}
+			setCORSHeaders(w, r, opts.cors)
+		}
+
+		// HEAD is served by GET handlers, net/http discards the body
+		if r.Method != method && !(method == http.MethodGet && r.Method == http.MethodHead) {
+			writeError(w, r, opts, &errorResponse{
+				Code:    http.StatusMethodNotAllowed,
+				Message: "invalid method used, expected " + method + " found " + r.Method,
+			})
+			return
+		}
+
+		// running is closed once a handler that outlived its timeout returns
+		var running <-chan struct{}
+
+		// shed load before any work is done for the request
+		if inFlight != nil {
+			if errResp := inFlight.acquire(); errResp != nil {
+				w.Header().Set(RetryAfter, "1")
+				writeError(w, r, opts, errResp)
+				return
+			}
+			// a timed out handler holds the slot until it returns
+			defer func() {
+				if running == nil {
+					inFlight.release()
+					return
+				}
+				go func(running <-chan struct{}) {
+					<-running
+					inFlight.release()
+				}(running)
+			}()
+		}
+
+		if opts.signatures != nil {
+			vr, errResp := verifyRequest(r, *opts.signatures, opts.maxBodySize)
+			if errResp != nil {
+				if limitErr := limitRequest(w, r, rateLimiters); limitErr != nil {
+					errResp = limitErr
+				}
+				writeError(w, r, opts, errResp)
+				return
+			}
+			r = vr
+		}
+
+		if len(opts.authenticators) > 0 {
+			ar, errResp := authenticate(w, r, opts.authenticators)
+			if errResp != nil {
+				// failed attempts are charged to the rate limits, credentials can not be guessed without limit
+				if limitErr := limitRequest(w, r, rateLimiters); limitErr != nil {
+					errResp = limitErr
+				}
+				writeError(w, r, opts, errResp)
+				return
+			}
+			r = ar
+		}
+		if errResp := authorizePrincipal(w, r, opts); errResp != nil {
+			writeError(w, r, opts, errResp)
+			return
+		}
+
+		if errResp := limitRequest(w, r, rateLimiters); errResp != nil {
+			writeError(w, r, opts, errResp)
+			return
+		}
+
+		if csrf != nil {
+			cr, errResp := csrf.protect(w, r)
+			if errResp != nil {
+				writeError(w, r, opts, errResp)
+				return
+			}
+			r = cr
+		}
+
+		// lost-update protection, modifications must be conditional. If-Unmodified-Since is only evaluated against
+		// the validators, Response.CheckVersion only compares If-Match
+		if opts.preconditionRequired && (r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodDelete) &&
+			len(r.Header.Get(IfMatch)) == 0 && (opts.validators == nil || len(r.Header.Get(IfUnmodifiedSince)) == 0) {
+			message := "precondition required, If-Match header is missing"
+			if opts.validators != nil {
+				message = "precondition required, If-Match or If-Unmodified-Since header is missing"
+			}
+			writeError(w, r, opts, &errorResponse{
+				Code:    http.StatusPreconditionRequired,
+				Message: message,
+			})
+			return
+		}
+
+		// evaluate preconditions against the resource's current validators before the handler runs
+		if opts.validators != nil {
+			v, err := opts.validators(r)
+			if err != nil {
+				writeError(w, r, opts, &errorResponse{
+					Code:    http.StatusInternalServerError,
+					Message: "failed to lookup resource validators",
+				})
+				return
+			}
+
+			switch checkPreconditions(r, quoteETag(v.ETag), v.LastModified) {
+			case http.StatusNotModified:
+				if len(v.ETag) > 0 {
+					w.Header().Set(ETag, quoteETag(v.ETag))
+				}
+				if !v.LastModified.IsZero() {
+					w.Header().Set(LastModified, v.LastModified.UTC().Format(http.TimeFormat))
+				}
+				setCacheHeaders(w, r, opts, http.StatusOK, nil)
+				writeNotModified(w)
+				return
+			case http.StatusPreconditionFailed:
+				writeError(w, r, opts, &errorResponse{
+					Code:    http.StatusPreconditionFailed,
+					Message: "precondition failed",
+				})
+				return
+			}
+		}
+
+		// read and decode the request body once, it is fingerprinted by the idempotency guard
+		var (
+			body        []byte
+			requestBody *I
+			present     bool
+		)
+		if mode != BodyIgnored {
+			present = hasBody(r)
+		}
+		switch {
+		case mode == BodyForbidden && present:
+			writeError(w, r, opts, &errorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "request body is not allowed",
+			})
+			return
+		case mode == BodyRequired && !present && !opts.decodePolicy.AllowEmptyBody:
+			writeError(w, r, opts, &errorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "missing request body",
+			})
+			return
+		}
+		if present || mode == BodyRequired {
+			dr, end := startPhase(r, opts, PhaseDecode)
+			var errResp *errorResponse
+			body, requestBody, errResp = decodeRequest[I](dr, opts)
+			end(errResp)
+			if rec != nil {
+				rec.requestSize = len(body)
+				rec.requestBody = requestBody
+			}
+			// request failed to unmarshal, return with failure
+			if errResp != nil {
+				if rec != nil {
+					rec.errPhase = PhaseDecode
+				}
+				writeError(w, r, opts, errResp)
+				return
+			}
+		}
+
+		if errResp := authorizeRequest(w, &Request[I]{r: r, body: requestBody, hasBody: present}, policies, opts.authenticators); errResp != nil {
+			writeError(w, r, opts, errResp)
+			return
+		}
+
+		// newRender returns a renderFunc that calls the handler and encodes its response, reporting to rec and, if
+		// observed, the observers
+		newRender := func(rec *requestRecord, observed bool) renderFunc {
+			o := opts
+			if !observed {
+				o = &unobserved
+			}
+			return func(w http.ResponseWriter, r *http.Request) (int, []byte, *errorResponse) {
+				hr, end := startPhase(r, o, PhaseHandler)
+				req := &Request[I]{r: hr, body: requestBody, hasBody: present}
+				response := &Response[O]{w: w, r: hr, statusCode: http.StatusOK}
+
+				// call the handler, background renders hold no in-flight slot
+				errResp, late := callHandler(fn, req, response, opts.timeout)
+				if observed && late != nil {
+					running = late
+				}
+				if errResp != nil {
+					end(errResp)
+					return 0, nil, errResp
+				}
+				end(response.err)
+
+				if rec != nil && response.body != nil {
+					rec.responseBody = response.body
+				}
+
+				// handler rejected the request, i.e a failed version check
+				if response.err != nil {
+					return 0, nil, response.err
+				}
+
+				// seekable bodies are streamed with support for range requests
+				if response.body != nil && response.marshal == nil && response.statusCode == http.StatusOK {
+					if content, ok := readSeeker(response.body); ok {
+						// response hooks need the body, responses are buffered if they are set
+						if _, buffered := w.(*headerRecorder); !buffered && len(opts.responseHooks) == 0 {
+							serveContent(w, r, content)
+							return statusWritten, nil, nil
+						}
+
+						b, err := bufferContent(w, content)
+						if err != nil {
+							return 0, nil, &errorResponse{
+								Code:    http.StatusInternalServerError,
+								Message: "failed to read response body",
+							}
+						}
+						setCacheHeaders(w, r, opts, response.statusCode, b)
+						return response.statusCode, b, nil
+					}
+				}
+
+				_, end = startPhase(r, o, PhaseEncode)
+				bodyBytes, negotiation, errResp := encodeResponse(r, response, opts)
+				end(errResp)
+				if rec != nil {
+					rec.negotiation = negotiation
+				}
+				// Response failed to marshal
+				if errResp != nil {
+					if rec != nil {
+						rec.errPhase = PhaseEncode
+					}
+					return 0, nil, errResp
+				}
+
+				setCacheHeaders(w, r, opts, response.statusCode, bodyBytes)
+				return response.statusCode, bodyBytes, nil
+			}
+		}
+		render := newRender(rec, true)
+
+		var (
+			statusCode int
+			bodyBytes  []byte
+			errResp    *errorResponse
+		)
+		switch {
+		case cache != nil && isSafe(r.Method):
+			// background revalidation outlives the request, it is not reported
+			statusCode, bodyBytes, errResp = cache.serve(w, r, render, newRender(nil, false))
+		case idempotency != nil && len(r.Header.Get(IdempotencyKey)) > 0:
+			statusCode, bodyBytes, errResp = idempotency.serve(w, r, body, render)
+		default:
+			statusCode, bodyBytes, errResp = render(w, r)
+		}
+		if errResp != nil {
+			writeError(w, r, opts, errResp)
+			return
+		}
+		if statusCode == statusWritten {
+			return
+		}
+
+		// the resource was modified, advertise its new version
+		if opts.validators != nil && !isSafe(r.Method) && statusCode >= 200 && statusCode <= 299 &&
+			len(w.Header().Get(ETag)) == 0 {
+			if v, err := opts.validators(r); err == nil && len(v.ETag) > 0 {
+				w.Header().Set(ETag, quoteETag(v.ETag))
+			}
+		}
+
+		if opts.hardening != nil {
+			setContentSecurityPolicy(w, opts.hardening)
+		}
+
+		// buffered seekable bodies, i.e from the response cache, support range requests. Hooks run for full
+		// responses, partial content is not the body they are computed from
+		if statusCode == http.StatusOK && w.Header().Get(AcceptRanges) == "bytes" {
+			if len(opts.responseHooks) > 0 && len(r.Header.Get(Range)) == 0 {
+				if errResp := runResponseHooks(w, r, opts.responseHooks, statusCode, bodyBytes); errResp != nil {
+					writeError(w, r, opts, errResp)
+					return
+				}
+			}
+			serveBuffered(w, r, bodyBytes)
+			return
+		}
+
+		bodyBytes, errResp = compressResponse(w, r, opts, bodyBytes)
+		if errResp != nil {
+			writeError(w, r, opts, errResp)
+			return
+		}
+
+		// conditional GET, the client's representation is still current
+		if isSafe(r.Method) && statusCode == http.StatusOK &&
+			checkPreconditions(r, w.Header().Get(ETag), lastModifiedHeader(w.Header())) == http.StatusNotModified {
+			writeNotModified(w)
+			return
+		}
+
+		if len(opts.responseHooks) > 0 {
+			if errResp := runResponseHooks(w, r, opts.responseHooks, statusCode, bodyBytes); errResp != nil {
+				writeError(w, r, opts, errResp)
+				return
+			}
+		}
+
+		// ensure user supplied status code is valid
+		if validStatusCode(statusCode) {
+			w.WriteHeader(statusCode)
+		}
+		if len(bodyBytes) > 0 {
+			w.Write(bodyBytes)
+		}
+	}
+}
+
+// hasBody reports whether the request has a body. A body of unknown length, i.e chunked, is peeked at.
+func hasBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return false
+	}
+	if r.ContentLength > 0 {
+		return true
+	}
+
+	var b [1]byte
+	n, _ := io.ReadFull(r.Body, b[:])
+	if n == 0 {
+		return false
+	}
+	body := r.Body
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b[:n]), body), body}
+	return true
+}
+
+// decodeRequest reads and unmarshals the request body based on the request's content-type.
+// An empty body fails with 400 unless the decode policy allows it, a missing or unsupported content-type fails
+// with 415 and a malformed body with 400.
+func decodeRequest[I Body](r *http.Request, opts *opts) ([]byte, *I, *errorResponse) {
+	var requestBody I
+	if r.Body == nil {
+		return nil, &requestBody, nil
+	}
+
+	b, errResp := readBody(r, opts.maxBodySize)
+	if errResp != nil {
+		return nil, nil, errResp
+	}
+
+	if len(b) == 0 {
+		if opts.decodePolicy.AllowEmptyBody {
+			return b, &requestBody, nil
+		}
+		return b, nil, &errorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "request body is empty",
+		}
+	}
+
+	contentType := r.Header.Get(ContentType)
+	if len(contentType) == 0 {
+		return b, nil, &errorResponse{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: "missing content-type",
+		}
+	}
+	mediaType, codec, ok := lookupCodec(contentType)
+	if !ok {
+		return b, nil, &errorResponse{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: "unsupported content-type " + contentType,
+		}
+	}
+
+	if err := unmarshalBody(mediaType, codec, b, &requestBody, opts); err != nil {
+		message := "failed to unmarshal request with content-type " + contentType
+		if isHygieneError(err) {
+			message = "invalid request body: " + err.Error()
+		}
+		return b, nil, &errorResponse{
+			Code:    http.StatusBadRequest,
+			Message: message,
+		}
+	}
+	return b, &requestBody, nil
+}
+
+// encodeResponse marshals the response body. The client's Accept header is preferred, falling back
+// to the response's Content-Type header and then to the default content-type.
+func encodeResponse[O Body](r *http.Request, response *Response[O], opts *opts) ([]byte, Negotiation, *errorResponse) {
+	if response.body == nil {
+		return nil, "", nil
+	}
+
+	// if there is a custom marshaler, prioritize it
+	if response.marshal != nil {
+		b, err := response.marshal(*response.body)
+		if err != nil {
+			return nil, NegotiatedCustom, &errorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "failed to marshal response with custom marhsaler",
+			}
+		}
+		return b, NegotiatedCustom, nil
+	}
+
+	// the representation depends on Accept, shared caches must not serve it to clients preferring another type
+	response.w.Header().Add(Vary, Accept)
+
+	// client preferred content-type
+	for _, mediaType := range acceptedMediaTypes(r.Header.Get(Accept)) {
+		b, err := marshalResponse(mediaType, response.body)
+		if err == nil {
+			response.w.Header().Set(ContentType, mediaType)
+			return b, NegotiatedAccept, nil
+		}
+	}
+
+	// server preferred content-type
+	negotiation := NegotiatedContentType
+	contentType := response.w.Header().Get(ContentType)
+	if len(contentType) == 0 {
+		negotiation = NegotiatedDefault
+		contentType = opts.defaultContentType
+	}
+	b, err := marshalResponse(contentType, response.body)
+	if err != nil {
+		return nil, negotiation, &errorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "failed to marshal response with content-type: " + contentType,
+		}
+	}
+	if len(response.w.Header().Get(ContentType)) == 0 {
+		response.w.Header().Set(ContentType, contentType)
+	}
+	return b, negotiation, nil
+}
+
+// writeError writes the error status code with an RFC 9457 problem details body. The error message is only
+// included if verbose is enabled. Requests of disconnected clients are only reported.
+func writeError(w http.ResponseWriter, r *http.Request, opts *opts, errResp *errorResponse) {
+	recordError(w, errResp)
+	// the client is gone, the status is only reported
+	if errResp.Code == statusClientClosedRequest {
+		recordStatus(w, errResp.Code)
+		return
+	}
+	p := problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(errResp.Code),
+		Status:    errResp.Code,
+		RequestID: ContextRequestID(r.Context()),
+	}
+	if opts.verbose {
+		p.Detail = errResp.Message
+	}
+	b, _ := json.Marshal(&p)
+	w.Header().Set(ContentType, ContentProblemJSON)
+	w.Header().Del(ContentEncoding)
+	if len(opts.responseHooks) > 0 {
+		// the error is reported even if a hook fails
+		runResponseHooks(w, r, opts.responseHooks, errResp.Code, b)
+	}
+	w.WriteHeader(errResp.Code)
+	w.Write(b)
+}
+
+func marshalResponse(contentType string, body Body) ([]byte, error) {
+	_, codec, ok := lookupCodec(contentType)
+	if !ok {
+		return nil, ErrUnsupportedResponseType
+	}
+	return codec.Marshal(body)
+}
@@ -0,0 +1,142 @@
+package glhf
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ContentDigest header constant, RFC 9530.
+	ContentDigest = "Content-Digest"
+	// WantContentDigest header constant, RFC 9530.
+	WantContentDigest = "Want-Content-Digest"
+)
+
+// EncodedResponse is a response once its body is encoded, content-coding included, before the status is written.
+type EncodedResponse struct {
+	// Request is the request being answered.
+	Request *http.Request
+	// StatusCode is the status that will be written.
+	StatusCode int
+	// ContentType is the Content-Type header of the response.
+	ContentType string
+	// Body is the final body, it is empty for responses without a body.
+	Body []byte
+	// Header is the response header, hooks may modify it.
+	Header http.Header
+}
+
+// ResponseHook is called with the encoded response before its status is written, i.e to set headers computed from
+// the body. A hook that fails the response returns an error.
+type ResponseHook func(res *EncodedResponse) error
+
+// ContentLengthHook sets the Content-Length header.
+func ContentLengthHook(res *EncodedResponse) error {
+	res.Header.Set("Content-Length", strconv.Itoa(len(res.Body)))
+	return nil
+}
+
+// DigestAlgorithm is a hash algorithm of the HTTP Digest Algorithm Values registry.
+type DigestAlgorithm string
+
+const (
+	// DigestSHA256 is the sha-256 digest algorithm.
+	DigestSHA256 DigestAlgorithm = "sha-256"
+	// DigestSHA512 is the sha-512 digest algorithm.
+	DigestSHA512 DigestAlgorithm = "sha-512"
+)
+
+func (a DigestAlgorithm) hash() (hash.Hash, bool) {
+	switch a {
+	case DigestSHA256:
+		return sha256.New(), true
+	case DigestSHA512:
+		return sha512.New(), true
+	}
+	return nil, false
+}
+
+// ContentDigestHook sets the Content-Digest header of responses with a body, RFC 9530. The digest uses the algorithms
+// preferred by the request's Want-Content-Digest header among algorithms, or every algorithm if the request has
+// no preference. Algorithms default to sha-256.
+func ContentDigestHook(algorithms ...DigestAlgorithm) ResponseHook {
+	if len(algorithms) == 0 {
+		algorithms = []DigestAlgorithm{DigestSHA256}
+	}
+	return func(res *EncodedResponse) error {
+		if len(res.Body) == 0 {
+			return nil
+		}
+		res.Header.Set(ContentDigest, contentDigest(res.Body, wantedDigests(res.Request.Header.Get(WantContentDigest), algorithms)))
+		return nil
+	}
+}
+
+// contentDigest returns the Content-Digest field value of b.
+func contentDigest(b []byte, algorithms []DigestAlgorithm) string {
+	digests := make([]string, 0, len(algorithms))
+	for _, a := range algorithms {
+		h, ok := a.hash()
+		if !ok {
+			continue
+		}
+		h.Write(b)
+		digests = append(digests, string(a)+"=:"+base64.StdEncoding.EncodeToString(h.Sum(nil))+":")
+	}
+	return strings.Join(digests, ", ")
+}
+
+// wantedDigests returns the algorithms of a Want-Content-Digest header supported by algorithms, by preference.
+// Without supported preferences every algorithm is returned.
+func wantedDigests(want string, algorithms []DigestAlgorithm) []DigestAlgorithm {
+	var (
+		best      DigestAlgorithm
+		bestValue = 0
+	)
+	for _, member := range strings.Split(want, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(member), "=")
+		preference, err := strconv.Atoi(value)
+		if err != nil || preference <= bestValue || !containsDigest(algorithms, DigestAlgorithm(key)) {
+			continue
+		}
+		best, bestValue = DigestAlgorithm(key), preference
+	}
+	if len(best) == 0 {
+		return algorithms
+	}
+	return []DigestAlgorithm{best}
+}
+
+func containsDigest(algorithms []DigestAlgorithm, a DigestAlgorithm) bool {
+	for _, v := range algorithms {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+// runResponseHooks calls hooks with the encoded response, it fails with 500 if a hook fails.
+func runResponseHooks(w http.ResponseWriter, r *http.Request, hooks []ResponseHook, statusCode int, b []byte) *errorResponse {
+	res := &EncodedResponse{
+		Request:     r,
+		StatusCode:  statusCode,
+		ContentType: w.Header().Get(ContentType),
+		Body:        b,
+		Header:      w.Header(),
+	}
+	for _, hook := range hooks {
+		if err := hook(res); err != nil {
+			return &errorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "response hook failed: " + err.Error(),
+			}
+		}
+	}
+	return nil
+}
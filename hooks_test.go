@@ -0,0 +1,78 @@
+package glhf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContentDigest(t *testing.T) {
+	// RFC 9530 example
+	body := []byte(`{"hello": "world"}`)
+
+	tests := []struct {
+		name       string
+		algorithms []DigestAlgorithm
+		want       string
+		expected   string
+	}{
+		{name: "default", expected: "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"},
+		{name: "wanted", algorithms: []DigestAlgorithm{DigestSHA256, DigestSHA512}, want: "sha-512=3, sha-256=10, md5=20",
+			expected: "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"},
+		{name: "unsupported preference", algorithms: []DigestAlgorithm{DigestSHA512}, want: "sha-256=1",
+			expected: "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if len(tt.want) > 0 {
+				r.Header.Set(WantContentDigest, tt.want)
+			}
+			res := &EncodedResponse{Request: r, Body: body, Header: make(http.Header)}
+			if err := ContentDigestHook(tt.algorithms...)(res); err != nil {
+				t.Fatal(err)
+			}
+			if v := res.Header.Get(ContentDigest); v != tt.expected {
+				t.Errorf("%s = %q; expected %q", ContentDigest, v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResponseHooks(t *testing.T) {
+	var calls []EncodedResponse
+	record := func(res *EncodedResponse) error {
+		calls = append(calls, *res)
+		return nil
+	}
+
+	handler := Post(func(r *Request[EmptyBody], w *Response[map[string]string]) {
+		w.SetStatus(http.StatusCreated)
+		w.SetBody(&map[string]string{"hello": "world"})
+	}, WithResponseHook(ContentLengthHook, record))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Length") != "17" {
+		t.Errorf("response = %d, Content-Length %q; expected %d, 17", w.Code, w.Header().Get("Content-Length"), http.StatusCreated)
+	}
+	if len(calls) != 1 || calls[0].StatusCode != http.StatusCreated || calls[0].ContentType != ContentJSON ||
+		string(calls[0].Body) != `{"hello":"world"}` {
+		t.Errorf("hook calls = %+v", calls)
+	}
+
+	// a failing hook fails the response
+	failing := Post(func(r *Request[EmptyBody], w *Response[EmptyBody]) {}, WithResponseHook(func(res *EncodedResponse) error {
+		if res.StatusCode == http.StatusOK {
+			return errors.New("no key")
+		}
+		return nil
+	}))
+	w = httptest.NewRecorder()
+	failing(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d; expected %d", w.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,234 @@
+package glhf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKey header constant.
+	IdempotencyKey = "Idempotency-Key"
+	// IdempotentReplayed header constant, set on responses replayed from the idempotency store.
+	IdempotentReplayed = "Idempotent-Replayed"
+
+	// defaultIdempotencyTTL is how long the response of an idempotent request is kept.
+	defaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyLockTTL is how long an in-flight request holds its key, so a crashed request does not block
+	// retries until the key expires. Handlers with a longer timeout hold it for their timeout.
+	idempotencyLockTTL = time.Minute
+
+	// sweepInterval is the number of insertions after which in-memory stores drop their expired entries.
+	sweepInterval = 1024
+)
+
+// idempotencyHeaders are the response headers stored with an idempotency record, the representation headers of
+// the response. Other headers, i.e cookies, rate limit or CORS headers, are computed for the retry.
+var idempotencyHeaders = []string{
+	ContentType,
+	"Content-Language",
+	"Content-Location",
+	"Location",
+	ETag,
+	LastModified,
+	Vary,
+}
+
+// IdempotencyRecord is the state of a request made with an Idempotency-Key.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request method, path and body the key was first used with.
+	Fingerprint string
+	// Completed is false while the first request is in flight.
+	Completed bool
+	// StatusCode is the http status code of the first response.
+	StatusCode int
+	// Header is the representation header of the first response, i.e Content-Type, Location and ETag.
+	Header http.Header
+	// Body is the encoded, uncompressed, body of the first response.
+	Body []byte
+}
+
+// IdempotencyStore stores the responses of requests made with an Idempotency-Key.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Reserve records an in-flight request for key, the reservation expires after ttl. If key is already recorded,
+	// the existing record is returned and reserved is false.
+	Reserve(key string, fingerprint string, ttl time.Duration) (existing *IdempotencyRecord, reserved bool, err error)
+	// Complete stores the response of the request that reserved key.
+	Complete(key string, record *IdempotencyRecord, ttl time.Duration) error
+	// Release removes the reservation of key so the request can be retried.
+	Release(key string) error
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*memoryIdempotencyRecord
+	inserts int
+}
+
+type memoryIdempotencyRecord struct {
+	record  *IdempotencyRecord
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records: make(map[string]*memoryIdempotencyRecord),
+	}
+}
+
+// Reserve records an in-flight request for key unless key is already recorded.
+func (s *MemoryIdempotencyStore) Reserve(key string, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if r, ok := s.records[key]; ok && now.Before(r.expires) {
+		return r.record, false, nil
+	}
+
+	// drop expired records every sweepInterval reservations, keeping reservations amortized constant time
+	if s.inserts++; s.inserts%sweepInterval == 0 {
+		for k, r := range s.records {
+			if !now.Before(r.expires) {
+				delete(s.records, k)
+			}
+		}
+	}
+
+	s.records[key] = &memoryIdempotencyRecord{
+		record:  &IdempotencyRecord{Fingerprint: fingerprint},
+		expires: now.Add(ttl),
+	}
+	return nil, true, nil
+}
+
+// Complete stores the response of the request that reserved key.
+func (s *MemoryIdempotencyStore) Complete(key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = &memoryIdempotencyRecord{
+		record:  record,
+		expires: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+// Release removes the reservation of key.
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// idempotencyGuard replays the first response of requests repeated with the same Idempotency-Key.
+type idempotencyGuard struct {
+	store   IdempotencyStore
+	ttl     time.Duration
+	lockTTL time.Duration
+}
+
+// newIdempotencyGuard returns a guard storing responses for ttl. In-flight requests hold their key for the
+// handler's timeout, or idempotencyLockTTL if it is shorter or not set.
+func newIdempotencyGuard(store IdempotencyStore, ttl time.Duration, timeout time.Duration) *idempotencyGuard {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	lockTTL := idempotencyLockTTL
+	if timeout > lockTTL {
+		lockTTL = timeout
+	}
+	return &idempotencyGuard{store: store, ttl: ttl, lockTTL: lockTTL}
+}
+
+// serve calls render for the first request made with the request's Idempotency-Key and replays its response
+// for later requests of the same principal with the same key and body. Headers of the response are copied to w.
+func (g *idempotencyGuard) serve(w http.ResponseWriter, r *http.Request, body []byte, render renderFunc) (int, []byte, *errorResponse) {
+	key := idempotencyStoreKey(r)
+	fingerprint := requestFingerprint(r, body)
+
+	existing, reserved, err := g.store.Reserve(key, fingerprint, g.lockTTL)
+	if err != nil {
+		return 0, nil, &errorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "failed to reserve idempotency key",
+		}
+	}
+
+	if !reserved {
+		switch {
+		case existing.Fingerprint != fingerprint:
+			return 0, nil, &errorResponse{
+				Code:    http.StatusUnprocessableEntity,
+				Message: "idempotency key was used with a different request",
+			}
+		case !existing.Completed:
+			return 0, nil, &errorResponse{
+				Code:    http.StatusConflict,
+				Message: "a request with the same idempotency key is in progress",
+			}
+		}
+
+		for k, v := range existing.Header {
+			w.Header()[k] = append([]string(nil), v...)
+		}
+		w.Header().Set(IdempotentReplayed, "true")
+		return existing.StatusCode, existing.Body, nil
+	}
+
+	// a panicking handler releases the key so the request can be retried
+	completed := false
+	defer func() {
+		if !completed {
+			g.store.Release(key)
+		}
+	}()
+
+	statusCode, b, errResp := render(w, r)
+	// failed requests are not stored so they can be retried, streamed responses can not be stored
+	if errResp != nil || statusCode >= http.StatusInternalServerError || statusCode == statusWritten {
+		return statusCode, b, errResp
+	}
+
+	record := &IdempotencyRecord{
+		Fingerprint: fingerprint,
+		Completed:   true,
+		StatusCode:  statusCode,
+		Header:      make(http.Header),
+		Body:        b,
+	}
+	for _, name := range idempotencyHeaders {
+		if v := w.Header().Values(name); len(v) > 0 {
+			record.Header[name] = append([]string(nil), v...)
+		}
+	}
+	if err := g.store.Complete(key, record, g.ttl); err == nil {
+		completed = true
+	}
+	return statusCode, b, nil
+}
+
+// idempotencyStoreKey returns the store key of a request's Idempotency-Key, scoped to the authenticated principal
+// so keys chosen by different clients never collide.
+func idempotencyStoreKey(r *http.Request) string {
+	var subject string
+	if p := ContextPrincipal(r.Context()); p != nil {
+		subject = p.Subject()
+	}
+	return subject + "\n" + r.Header.Get(IdempotencyKey)
+}
+
+// requestFingerprint identifies a request by its method, path and decoded body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
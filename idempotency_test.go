@@ -0,0 +1,114 @@
+package glhf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIdempotency(t *testing.T) {
+	type todo struct {
+		ID string `json:"id"`
+	}
+
+	var calls int
+	started, block := make(chan struct{}), make(chan struct{})
+	handler := Post(func(r *Request[todo], w *Response[todo]) {
+		calls++
+		if r.Body().ID == "slow" {
+			close(started)
+			<-block
+		}
+		w.SetStatus(http.StatusCreated)
+		w.SetBody(r.Body())
+	}, WithIdempotency(NewMemoryIdempotencyStore(), 0))
+
+	post := func(key string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(body))
+		r.Header.Set(ContentType, ContentJSON)
+		r.Header.Set(IdempotencyKey, key)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	first := post("a", `{"id":"1"}`)
+	replay := post("a", `{"id":"1"}`)
+	if calls != 1 || replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s after %d calls; expected the first response", replay.Code, replay.Body.String(), calls)
+	}
+	if replay.Header().Get(IdempotentReplayed) != "true" {
+		t.Errorf("%s header missing on replay", IdempotentReplayed)
+	}
+
+	if w := post("a", `{"id":"2"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d; expected %d for a different body", w.Code, http.StatusUnprocessableEntity)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		post("b", `{"id":"slow"}`)
+		close(done)
+	}()
+	<-started
+	if w := post("b", `{"id":"slow"}`); w.Code != http.StatusConflict {
+		t.Errorf("status = %d; expected %d while the first request is in flight", w.Code, http.StatusConflict)
+	}
+	close(block)
+	<-done
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	type todo struct {
+		ID string `json:"id"`
+	}
+
+	var calls int
+	handler := Post(func(r *Request[todo], w *Response[todo]) {
+		calls++
+		if r.Body().ID == "panic" && calls == 1 {
+			panic("handler failed")
+		}
+		w.SetHeader("Set-Cookie", "session="+r.Principal().Subject())
+		w.SetHeader("Location", "/todo/"+r.Body().ID)
+		w.SetStatus(http.StatusCreated)
+		w.SetBody(&todo{ID: r.Principal().Subject()})
+	}, WithIdempotency(NewMemoryIdempotencyStore(), 0))
+
+	post := func(user string, key string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(body))
+		r = r.WithContext(ContextWithPrincipal(r.Context(), &testUser{name: user}))
+		r.Header.Set(ContentType, ContentJSON)
+		r.Header.Set(IdempotencyKey, key)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	post("alice", "a", `{"id":"1"}`)
+	// another principal reusing the key and body is not handed alice's response
+	if w := post("bob", "a", `{"id":"1"}`); calls != 2 || w.Body.String() != `{"id":"bob"}` {
+		t.Errorf("response = %s after %d calls; expected bob's own response", w.Body.String(), calls)
+	}
+
+	// only representation headers are replayed
+	w := post("alice", "a", `{"id":"1"}`)
+	if calls != 2 || w.Header().Get("Location") != "/todo/1" || len(w.Header().Get("Set-Cookie")) > 0 {
+		t.Errorf("replay header = %v after %d calls; expected Location without Set-Cookie", w.Header(), calls)
+	}
+
+	// a panicking handler releases the key
+	calls = 0
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the handler to panic")
+			}
+		}()
+		post("alice", "b", `{"id":"panic"}`)
+	}()
+	if w := post("alice", "b", `{"id":"panic"}`); w.Code != http.StatusCreated {
+		t.Errorf("status = %d; expected the retry to be handled", w.Code)
+	}
+}
@@ -0,0 +1,366 @@
+package glhf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JWK is a key used to verify JSON Web Tokens.
+type JWK struct {
+	// KeyID matches the kid header of tokens signed with the key, optional.
+	KeyID string
+	// Algorithm restricts the key to one signing algorithm, i.e RS256, optional.
+	Algorithm string
+	// Key is a *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey or a []byte HMAC secret.
+	Key any
+}
+
+// jsonWebKey is the JSON representation of a JWK (RFC 7517).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses a JSON Web Key Set, i.e a local copy of an identity provider's jwks.json.
+// Keys that are not used for signatures are skipped.
+func ParseJWKS(b []byte) ([]JWK, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make([]JWK, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if len(k.Use) > 0 && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, JWK{KeyID: k.Kid, Algorithm: k.Alg, Key: key})
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			return nil, ErrInvalidKey
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrInvalidKey
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, ErrInvalidKey
+		}
+		return key, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidKey
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, ErrInvalidKey
+		}
+		return secret, nil
+	default:
+		return nil, ErrInvalidKey
+	}
+}
+
+// JWTConfig configures the validation of bearer JSON Web Tokens.
+type JWTConfig struct {
+	// Keys verify the token signatures, see ParseJWKS.
+	Keys []JWK
+	// Issuer is the required iss claim, optional.
+	Issuer string
+	// Audience is the required aud claim, optional.
+	Audience string
+	// Leeway is the clock skew tolerated when validating the exp and nbf claims.
+	Leeway time.Duration
+	// Realm is the realm of the WWW-Authenticate challenge.
+	Realm string
+}
+
+// Claims is the Principal of a request authenticated with a JSON Web Token.
+type Claims map[string]any
+
+// Subject returns the sub claim.
+func (c Claims) Subject() string {
+	sub, _ := c["sub"].(string)
+	return sub
+}
+
+// Scopes returns the space separated scope claim, or the scp claim.
+func (c Claims) Scopes() []string {
+	if scope, ok := c["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	return c.strings("scp")
+}
+
+// Roles returns the roles claim.
+func (c Claims) Roles() []string {
+	return c.strings("roles")
+}
+
+// strings returns a claim that is a string or an array of strings.
+func (c Claims) strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// time returns a NumericDate claim, ok is false if the claim is absent. A claim that is not a number fails with
+// ErrInvalidToken, it must not disable the check it is used by.
+func (c Claims) time(name string) (t time.Time, ok bool, err error) {
+	v, present := c[name]
+	if !present {
+		return time.Time{}, false, nil
+	}
+	n, isNumber := v.(json.Number)
+	if !isNumber {
+		return time.Time{}, false, ErrInvalidToken
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, ErrInvalidToken
+	}
+	return time.Unix(int64(f), 0), true, nil
+}
+
+type jwtAuthenticator struct {
+	config JWTConfig
+	now    func() time.Time
+}
+
+// NewJWTAuthenticator returns an Authenticator for bearer JSON Web Tokens (RFC 7519) signed with one of the
+// configured keys. The principal is the token's Claims.
+func NewJWTAuthenticator(config JWTConfig) Authenticator {
+	return &jwtAuthenticator{config: config, now: time.Now}
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get(Authorization), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+	return a.verify(strings.TrimSpace(token))
+}
+
+func (a *jwtAuthenticator) Challenge(err error) string {
+	challenge := "Bearer realm=" + strconv.Quote(a.config.Realm)
+	if err != nil {
+		challenge += `, error="invalid_token", error_description=` + strconv.Quote(err.Error())
+	}
+	return challenge
+}
+
+// verify checks the token's signature and its exp, nbf, iss and aud claims.
+func (a *jwtAuthenticator) verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range a.config.Keys {
+		if (len(header.Kid) > 0 && len(key.KeyID) > 0 && key.KeyID != header.Kid) ||
+			(len(key.Algorithm) > 0 && key.Algorithm != header.Alg) {
+			continue
+		}
+		if verifySignature(header.Alg, key.Key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidSignature
+	}
+
+	claims := Claims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := a.now()
+	exp, ok, err := claims.time("exp")
+	if err != nil {
+		return nil, err
+	}
+	if ok && !now.Before(exp.Add(a.config.Leeway)) {
+		return nil, ErrTokenExpired
+	}
+	nbf, ok, err := claims.time("nbf")
+	if err != nil {
+		return nil, err
+	}
+	if ok && now.Add(a.config.Leeway).Before(nbf) {
+		return nil, ErrTokenNotValidYet
+	}
+	if len(a.config.Issuer) > 0 && claims["iss"] != a.config.Issuer {
+		return nil, ErrInvalidIssuer
+	}
+	if len(a.config.Audience) > 0 && !containsString(claims.strings("aud"), a.config.Audience) {
+		return nil, ErrInvalidAudience
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token, numbers are kept as json.Number.
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// verifySignature verifies a JWS signature (RFC 7518 section 3). The none algorithm is never accepted.
+func verifySignature(alg string, key any, signed []byte, signature []byte) bool {
+	if alg == "EdDSA" {
+		k, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(k, signed, signature)
+	}
+	if len(alg) != 5 {
+		return false
+	}
+
+	var (
+		hash      crypto.Hash
+		curveBits int
+	)
+	switch alg[2:] {
+	case "256":
+		hash, curveBits = crypto.SHA256, 256
+	case "384":
+		hash, curveBits = crypto.SHA384, 384
+	case "512":
+		hash, curveBits = crypto.SHA512, 521
+	default:
+		return false
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case "RS":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+	case "PS":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPSS(k, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || k.Curve.Params().BitSize != curveBits {
+			return false
+		}
+		size := (curveBits + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	default:
+		return false
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
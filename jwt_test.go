@@ -0,0 +1,175 @@
+package glhf
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// signJWT returns a token for claims signed with key using alg.
+func signJWT(t *testing.T, alg string, kid string, key any, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var (
+		sig []byte
+		err error
+	)
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, signErr := ecdsa.Sign(rand.Reader, k, digest[:])
+		sig, err = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...), signErr
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPublic, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	secret := []byte("secret")
+
+	keys := []JWK{
+		{KeyID: "rsa", Key: &rsaKey.PublicKey},
+		{KeyID: "ec", Key: &ecKey.PublicKey},
+		{KeyID: "ed", Key: edPublic},
+		{KeyID: "hmac", Algorithm: "HS256", Key: secret},
+	}
+	a := NewJWTAuthenticator(JWTConfig{Keys: keys, Issuer: "glhf", Audience: "api", Leeway: time.Minute})
+
+	now := time.Now().Unix()
+	valid := map[string]any{"sub": "alice", "iss": "glhf", "aud": []string{"api"}, "exp": now + 60, "scope": "todo:read todo:write"}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{name: "RS256", token: signJWT(t, "RS256", "rsa", rsaKey, valid)},
+		{name: "ES256", token: signJWT(t, "ES256", "ec", ecKey, valid)},
+		{name: "EdDSA", token: signJWT(t, "EdDSA", "ed", edKey, valid)},
+		{name: "HS256", token: signJWT(t, "HS256", "hmac", secret, valid)},
+		{name: "unknown kid", token: signJWT(t, "RS256", "other", rsaKey, valid), err: ErrInvalidSignature},
+		{name: "algorithm confusion", token: signJWT(t, "HS256", "rsa", secret, valid), err: ErrInvalidSignature},
+		{name: "none", token: signJWT(t, "none", "", nil, valid), err: ErrInvalidSignature},
+		{name: "malformed", token: "token", err: ErrInvalidToken},
+		{
+			name:  "expired",
+			token: signJWT(t, "RS256", "rsa", rsaKey, map[string]any{"sub": "alice", "iss": "glhf", "aud": "api", "exp": now - 120}),
+			err:   ErrTokenExpired,
+		},
+		{
+			name:  "expired within leeway",
+			token: signJWT(t, "RS256", "rsa", rsaKey, map[string]any{"sub": "alice", "iss": "glhf", "aud": "api", "exp": now - 30}),
+		},
+		{
+			name:  "not valid yet",
+			token: signJWT(t, "RS256", "rsa", rsaKey, map[string]any{"sub": "alice", "iss": "glhf", "aud": "api", "nbf": now + 120}),
+			err:   ErrTokenNotValidYet,
+		},
+		{
+			name:  "string exp",
+			token: signJWT(t, "RS256", "rsa", rsaKey, map[string]any{"sub": "alice", "iss": "glhf", "aud": "api", "exp": "1"}),
+			err:   ErrInvalidToken,
+		},
+		{
+			name:  "string nbf",
+			token: signJWT(t, "RS256", "rsa", rsaKey, map[string]any{"sub": "alice", "iss": "glhf", "aud": "api", "nbf": "1"}),
+			err:   ErrInvalidToken,
+		},
+		{
+			name:  "issuer",
+			token: signJWT(t, "RS256", "rsa", rsaKey, map[string]any{"sub": "alice", "iss": "other", "aud": "api"}),
+			err:   ErrInvalidIssuer,
+		},
+		{
+			name:  "audience",
+			token: signJWT(t, "RS256", "rsa", rsaKey, map[string]any{"sub": "alice", "iss": "glhf", "aud": "other"}),
+			err:   ErrInvalidAudience,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(Authorization, "Bearer "+tt.token)
+			p, err := a.Authenticate(r)
+			if err != tt.err {
+				t.Fatalf("err = %v; expected %v", err, tt.err)
+			}
+			if err == nil && p.Subject() != "alice" {
+				t.Errorf("subject = %s; expected alice", p.Subject())
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(Authorization, "Bearer "+signJWT(t, "RS256", "rsa", rsaKey, valid))
+	p, _ := a.Authenticate(r)
+	if scopes := p.(Claims).Scopes(); len(scopes) != 2 || scopes[1] != "todo:write" {
+		t.Errorf("scopes = %v; expected [todo:read todo:write]", scopes)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	enc := base64.RawURLEncoding.EncodeToString
+
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "use": "sig", "n": enc(rsaKey.N.Bytes()), "e": enc(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": enc(ecKey.X.FillBytes(make([]byte, 32))), "y": enc(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": enc(rsaKey.N.Bytes()), "e": "AQAB"},
+	}})
+
+	keys, err := ParseJWKS(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("found %d keys; expected 2", len(keys))
+	}
+
+	a := NewJWTAuthenticator(JWTConfig{Keys: keys})
+	for _, token := range []string{
+		signJWT(t, "RS256", "rsa", rsaKey, map[string]any{"sub": "alice"}),
+		signJWT(t, "ES256", "ec", ecKey, map[string]any{"sub": "alice"}),
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(Authorization, "Bearer "+token)
+		if _, err := a.Authenticate(r); err != nil {
+			t.Errorf("token signed with jwks key failed: %v", err)
+		}
+	}
+
+	if _, err := ParseJWKS([]byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}]}`)); err != ErrInvalidKey {
+		t.Errorf("err = %v; expected %v", err, ErrInvalidKey)
+	}
+}
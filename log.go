@@ -0,0 +1,225 @@
+package glhf
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	// redacted replaces the value of fields tagged with `glhf:"redact"` in logged bodies.
+	redacted = "[REDACTED]"
+	// truncated replaces cyclic or too deeply nested values in logged bodies.
+	truncated = "[TRUNCATED]"
+)
+
+// logRequest emits one log record for a request. Server errors are logged at error level,
+// client errors at warn level and everything else at info level.
+func logRequest(logger *slog.Logger, opts *opts, rec *requestRecord, info RequestInfo, r *http.Request) {
+	level := slog.LevelInfo
+	switch {
+	case info.StatusCode >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case info.StatusCode >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", info.Method),
+		slog.String("route", info.Route),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", info.StatusCode),
+		slog.String("request_content_type", info.RequestContentType),
+		slog.String("response_content_type", info.ResponseContentType),
+		slog.Int("request_size", info.RequestSize),
+		slog.Int("response_size", info.ResponseSize),
+		slog.Duration("duration", info.Duration),
+	}
+	if len(info.RequestID) > 0 {
+		attrs = append(attrs, slog.String("request_id", info.RequestID))
+	}
+	if info.Err != nil {
+		attrs = append(attrs, slog.String("error", info.Err.Error()))
+	}
+	if opts.logBodies {
+		if rec.requestBody != nil {
+			attrs = append(attrs, slog.Any("request_body", redact(rec.requestBody)))
+		}
+		if rec.responseBody != nil {
+			attrs = append(attrs, slog.Any("response_body", redact(rec.responseBody)))
+		}
+	}
+
+	logger.LogAttrs(r.Context(), level, "glhf request", attrs...)
+}
+
+// redact returns v as a loggable value, replacing fields tagged with `glhf:"redact"`.
+// Struct fields are named after their json tag. Values implementing slog.LogValuer are logged as they represent
+// themselves, values implementing json.Marshaler or fmt.Stringer, i.e time.Time, too unless they have redacted fields.
+func redact(v any) any {
+	return redactValue(reflect.ValueOf(v), 0, map[uintptr]bool{})
+}
+
+// maxRedactDepth is the maximum nesting depth of a redacted value, deeper values are logged as truncated.
+const maxRedactDepth = 32
+
+// redactValue redacts v at depth. seen holds the pointers and maps being redacted on the current path, a value
+// referencing one of them is cyclic and logged as truncated.
+func redactValue(v reflect.Value, depth int, seen map[uintptr]bool) any {
+	if depth > maxRedactDepth {
+		return truncated
+	}
+
+	for {
+		if out, ok := selfLogged(v); ok {
+			return out
+		}
+		if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface {
+			break
+		}
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Pointer {
+			if seen[v.Pointer()] {
+				return truncated
+			}
+			seen[v.Pointer()] = true
+			defer delete(seen, v.Pointer())
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := map[string]any{}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if len(name) == 0 {
+				name = f.Name
+			}
+
+			if f.Tag.Get("glhf") == "redact" {
+				fields[name] = redacted
+				continue
+			}
+			fields[name] = redactValue(v.Field(i), depth+1, seen)
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		// binary data is logged by its length
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Len()
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = redactValue(v.Index(i), depth+1, seen)
+		}
+		return items
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		if seen[v.Pointer()] {
+			return truncated
+		}
+		seen[v.Pointer()] = true
+		defer delete(seen, v.Pointer())
+
+		items := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			items[toString(iter.Key())] = redactValue(iter.Value(), depth+1, seen)
+		}
+		return items
+	case reflect.Invalid, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil
+	default:
+		return v.Interface()
+	}
+}
+
+// selfLogged returns the representation of values implementing slog.LogValuer, json.Marshaler or fmt.Stringer.
+func selfLogged(v reflect.Value) (any, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil, false
+		}
+	}
+
+	// a value with redacted fields is walked, its own representation would log them in clear
+	if _, ok := v.Interface().(slog.LogValuer); !ok && hasRedactedFields(v.Type(), map[reflect.Type]bool{}) {
+		return nil, false
+	}
+
+	switch x := v.Interface().(type) {
+	case slog.LogValuer:
+		return x.LogValue().Resolve().Any(), true
+	case json.Marshaler:
+		b, err := x.MarshalJSON()
+		if err != nil {
+			return nil, false
+		}
+		var out any
+		if err := json.Unmarshal(b, &out); err != nil {
+			return string(b), true
+		}
+		return out, true
+	case fmt.Stringer:
+		return x.String(), true
+	}
+
+	// methods with a pointer receiver
+	if v.Kind() != reflect.Pointer && v.CanAddr() {
+		return selfLogged(v.Addr())
+	}
+	return nil, false
+}
+
+// hasRedactedFields reports whether t, or a type it contains, has an exported field tagged with `glhf:"redact"`.
+// seen holds the types being checked, recursive types are checked once.
+func hasRedactedFields(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return hasRedactedFields(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if f.Tag.Get("glhf") == "redact" || hasRedactedFields(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func toString(v reflect.Value) string {
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	return strings.TrimSpace(slog.AnyValue(v.Interface()).String())
+}
@@ -0,0 +1,124 @@
+package glhf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	type login struct {
+		User     string `json:"user"`
+		Password string `json:"password" glhf:"redact"`
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := Post(func(r *Request[login], w *Response[login]) {
+		w.SetBody(r.Body())
+	}, WithLogger(logger), WithBodyLogging(true), WithRoute("/login"))
+
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"user":"glhf","password":"secret"}`))
+	r.Header.Set(ContentType, ContentJSON)
+	handler(httptest.NewRecorder(), r)
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("log record contains redacted field: %s", buf.String())
+	}
+
+	record := map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"route":                 "/login",
+		"status":                float64(http.StatusOK),
+		"request_content_type":  ContentJSON,
+		"response_content_type": ContentJSON,
+		"request_size":          float64(35),
+	}
+	for k, v := range expected {
+		if record[k] != v {
+			t.Errorf("%s = %v; expected %v", k, record[k], v)
+		}
+	}
+
+	buf.Reset()
+	r = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{`))
+	r.Header.Set(ContentType, ContentJSON)
+	handler(httptest.NewRecorder(), r)
+	if !strings.Contains(buf.String(), `"error":"failed to unmarshal request`) || !strings.Contains(buf.String(), `"level":"WARN"`) {
+		t.Errorf("log record missing decode error: %s", buf.String())
+	}
+}
+
+type testID [2]byte
+
+func (id testID) String() string {
+	return fmt.Sprintf("%x", id[:])
+}
+
+type testToken string
+
+func (testToken) LogValue() slog.Value {
+	return slog.StringValue("token")
+}
+
+type testCredentials struct {
+	User     string `json:"user"`
+	Password string `json:"password" glhf:"redact"`
+}
+
+func (c testCredentials) String() string {
+	return c.User + ":" + c.Password
+}
+
+type testNode struct {
+	Name string    `json:"name"`
+	Next *testNode `json:"next"`
+}
+
+func TestRedact(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cyclic := &testNode{Name: "a"}
+	cyclic.Next = cyclic
+	cyclicMap := map[string]any{}
+	cyclicMap["self"] = cyclicMap
+
+	testCases := []struct {
+		name     string
+		value    any
+		expected any
+	}{
+		{"marshaler", struct {
+			Created time.Time `json:"created"`
+		}{created}, map[string]any{"created": "2024-01-02T03:04:05Z"}},
+		{"stringer", struct {
+			ID testID `json:"id"`
+		}{testID{0xab, 0xcd}}, map[string]any{"id": "abcd"}},
+		{"log valuer", struct {
+			Token testToken `json:"token"`
+		}{"secret"}, map[string]any{"token": "token"}},
+		{"redacted stringer", testCredentials{User: "u", Password: "hunter2"}, map[string]any{"user": "u", "password": redacted}},
+		{"redacted stringer field", struct {
+			Credentials *testCredentials `json:"credentials"`
+		}{&testCredentials{User: "u", Password: "hunter2"}}, map[string]any{"credentials": map[string]any{"user": "u", "password": redacted}}},
+		{"cyclic pointer", cyclic, map[string]any{"name": "a", "next": truncated}},
+		{"cyclic map", cyclicMap, map[string]any{"self": truncated}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := redact(testCase.value); !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("redact() = %#v; expected %#v", actual, testCase.expected)
+			}
+		})
+	}
+}
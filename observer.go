@@ -0,0 +1,199 @@
+package glhf
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Phase is a stage of the glhf request pipeline.
+type Phase string
+
+const (
+	// PhaseDecode reads and unmarshals the request body.
+	PhaseDecode Phase = "decode"
+	// PhaseHandler calls the HandleFunc.
+	PhaseHandler Phase = "handler"
+	// PhaseEncode marshals the response body.
+	PhaseEncode Phase = "encode"
+)
+
+// UnknownRoute is the route of requests to handlers without a route set by WithRoute or a Router. Request paths are
+// not used as routes, IDs in paths would make the number of routes reported to metrics unbounded.
+const UnknownRoute = "unknown"
+
+// Negotiation describes how the response content-type was chosen.
+type Negotiation string
+
+const (
+	// NegotiatedAccept content-type matched the request's Accept header.
+	NegotiatedAccept Negotiation = "accept"
+	// NegotiatedContentType content-type was set by the handler.
+	NegotiatedContentType Negotiation = "content-type"
+	// NegotiatedDefault content-type is the route's default, the Accept header could not be satisfied.
+	NegotiatedDefault Negotiation = "default"
+	// NegotiatedCustom response was marshaled by the handler's MarshalFunc.
+	NegotiatedCustom Negotiation = "custom"
+)
+
+// RequestInfo describes a request handled by glhf.
+type RequestInfo struct {
+	// Route is the route name set with WithRoute or the Router pattern, or UnknownRoute.
+	Route string
+	// Method is the request method.
+	Method string
+	// RequestID is the request ID, set if request IDs are enabled.
+	RequestID string
+	// StatusCode is the response status code.
+	StatusCode int
+	// RequestContentType is the request's Content-Type header.
+	RequestContentType string
+	// ResponseContentType is the response's Content-Type header.
+	ResponseContentType string
+	// Negotiation describes how the response content-type was chosen, empty if the response has no body.
+	Negotiation Negotiation
+	// RequestSize is the size of the decoded request body in bytes.
+	RequestSize int
+	// ResponseSize is the number of response body bytes written.
+	ResponseSize int
+	// Duration is the time spent handling the request.
+	Duration time.Duration
+	// Err is the error glhf responded with, if any.
+	Err error
+	// ErrPhase is the phase that failed, empty if Err is not a decode or encode error.
+	ErrPhase Phase
+}
+
+// Observer instruments the requests handled by glhf, i.e with tracing or metrics.
+// Implementations must be safe for concurrent use.
+type Observer interface {
+	// StartRequest is called when a request is received, the returned request is used for the rest of the pipeline.
+	StartRequest(r *http.Request, route string) *http.Request
+	// StartPhase is called when a phase starts, the returned context is used during the phase and end is called
+	// with the phase's error once it completes.
+	StartPhase(ctx context.Context, phase Phase) (phaseCtx context.Context, end func(err error))
+	// EndRequest is called once the response is written with the context of the request returned by StartRequest.
+	EndRequest(ctx context.Context, info RequestInfo)
+}
+
+// requestRecord collects what is reported about a request to the logger and observers.
+type requestRecord struct {
+	start        time.Time
+	route        string
+	requestSize  int
+	requestBody  any
+	responseBody any
+	negotiation  Negotiation
+	errPhase     Phase
+}
+
+// recordingWriter records the status code, size and error of a response.
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	size       int
+	err        *errorResponse
+}
+
+func (rw *recordingWriter) WriteHeader(statusCode int) {
+	if rw.statusCode == 0 {
+		rw.statusCode = statusCode
+	}
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.statusCode = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+	return n, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter, used by http.ResponseController.
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// recordError records the error of a failed request on w if it is reported.
+func recordError(w http.ResponseWriter, errResp *errorResponse) {
+	if rw, ok := w.(*recordingWriter); ok {
+		rw.err = errResp
+	}
+}
+
+// recordStatus records the status of a response that is not written, i.e to a client that disconnected.
+func recordStatus(w http.ResponseWriter, statusCode int) {
+	if rw, ok := w.(*recordingWriter); ok && rw.statusCode == 0 {
+		rw.statusCode = statusCode
+	}
+}
+
+// startRecord starts reporting a request to the logger and observers of opts. It returns nil if nothing is reported.
+func startRecord(w http.ResponseWriter, r *http.Request, opts *opts) (*requestRecord, *recordingWriter, *http.Request) {
+	if opts.logger == nil && len(opts.observers) == 0 {
+		return nil, nil, r
+	}
+
+	rec := &requestRecord{start: time.Now(), route: opts.route}
+	if len(rec.route) == 0 {
+		rec.route = UnknownRoute
+	}
+	for _, o := range opts.observers {
+		r = o.StartRequest(r, rec.route)
+	}
+	return rec, &recordingWriter{ResponseWriter: w}, r
+}
+
+// finish reports the completed request to the logger and observers.
+func (rec *requestRecord) finish(opts *opts, rw *recordingWriter, r *http.Request) {
+	info := RequestInfo{
+		Route:               rec.route,
+		Method:              r.Method,
+		RequestID:           ContextRequestID(r.Context()),
+		StatusCode:          rw.statusCode,
+		RequestContentType:  r.Header.Get(ContentType),
+		ResponseContentType: rw.Header().Get(ContentType),
+		Negotiation:         rec.negotiation,
+		RequestSize:         rec.requestSize,
+		ResponseSize:        rw.size,
+		Duration:            time.Since(rec.start),
+	}
+	if info.StatusCode == 0 {
+		info.StatusCode = http.StatusOK
+	}
+	if rw.err != nil {
+		info.Err = rw.err
+		info.ErrPhase = rec.errPhase
+	}
+
+	if opts.logger != nil {
+		logRequest(opts.logger, opts, rec, info, r)
+	}
+	for _, o := range opts.observers {
+		o.EndRequest(r.Context(), info)
+	}
+}
+
+// startPhase notifies the observers of opts that phase started. The returned request carries the phase's context.
+func startPhase(r *http.Request, opts *opts, phase Phase) (*http.Request, func(errResp *errorResponse)) {
+	if len(opts.observers) == 0 {
+		return r, func(*errorResponse) {}
+	}
+
+	ctx := r.Context()
+	ends := make([]func(error), len(opts.observers))
+	for i, o := range opts.observers {
+		ctx, ends[i] = o.StartPhase(ctx, phase)
+	}
+	return r.WithContext(ctx), func(errResp *errorResponse) {
+		var err error
+		if errResp != nil {
+			err = errResp
+		}
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package glhf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testObserver records the phases and requests it observes.
+type testObserver struct {
+	mu     sync.Mutex
+	events []string
+	infos  []RequestInfo
+}
+
+func (o *testObserver) StartRequest(r *http.Request, route string) *http.Request {
+	o.record("start " + route)
+	return r
+}
+
+func (o *testObserver) StartPhase(ctx context.Context, phase Phase) (context.Context, func(error)) {
+	o.record("start " + string(phase))
+	return ctx, func(err error) {
+		if err != nil {
+			o.record("fail " + string(phase))
+			return
+		}
+		o.record("end " + string(phase))
+	}
+}
+
+func (o *testObserver) EndRequest(ctx context.Context, info RequestInfo) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, "end request")
+	o.infos = append(o.infos, info)
+}
+
+func (o *testObserver) record(event string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, event)
+}
+
+func TestObserver(t *testing.T) {
+	type test struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		accept      string
+		events      []string
+		statusCode  int
+		negotiation Negotiation
+		errPhase    Phase
+	}{
+		{
+			name:        "accept",
+			body:        `{"name":"glhf"}`,
+			accept:      ContentJSON,
+			events:      []string{"start /test", "start decode", "end decode", "start handler", "end handler", "start encode", "end encode", "end request"},
+			statusCode:  http.StatusOK,
+			negotiation: NegotiatedAccept,
+		},
+		{
+			name:        "default",
+			body:        `{"name":"glhf"}`,
+			accept:      "text/csv",
+			events:      []string{"start /test", "start decode", "end decode", "start handler", "end handler", "start encode", "end encode", "end request"},
+			statusCode:  http.StatusOK,
+			negotiation: NegotiatedDefault,
+		},
+		{
+			name:       "decode error",
+			body:       `{`,
+			events:     []string{"start /test", "start decode", "fail decode", "end request"},
+			statusCode: http.StatusBadRequest,
+			errPhase:   PhaseDecode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &testObserver{}
+			handler := Post(func(r *Request[test], w *Response[test]) {
+				w.SetBody(r.Body())
+			}, WithObserver(o), WithRoute("/test"))
+
+			r := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tt.body))
+			r.Header.Set(ContentType, ContentJSON)
+			r.Header.Set(Accept, tt.accept)
+			handler(httptest.NewRecorder(), r)
+
+			if !reflect.DeepEqual(o.events, tt.events) {
+				t.Errorf("events = %v; expected %v", o.events, tt.events)
+			}
+			info := o.infos[0]
+			if info.StatusCode != tt.statusCode || info.Negotiation != tt.negotiation || info.ErrPhase != tt.errPhase {
+				t.Errorf("info = %d %q %q; expected %d %q %q", info.StatusCode, info.Negotiation, info.ErrPhase,
+					tt.statusCode, tt.negotiation, tt.errPhase)
+			}
+		})
+	}
+}
+
+func TestObserverRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(o Observer) http.Handler
+		route   string
+	}{
+		{name: "unknown", handler: func(o Observer) http.Handler {
+			return Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {}, WithObserver(o))
+		}, route: UnknownRoute},
+		{name: "option", handler: func(o Observer) http.Handler {
+			return Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {}, WithObserver(o), WithRoute("/todo/{id}"))
+		}, route: "/todo/{id}"},
+		{name: "router", handler: func(o Observer) http.Handler {
+			rt := NewRouter(WithObserver(o))
+			Handle(rt, http.MethodGet, "/todo/", func(r *Request[EmptyBody], w *Response[EmptyBody]) {})
+			return rt
+		}, route: "/todo/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &testObserver{}
+			tt.handler(o).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/todo/42", nil))
+			if len(o.infos) != 1 || o.infos[0].Route != tt.route {
+				t.Errorf("infos = %+v; expected route %q", o.infos, tt.route)
+			}
+		})
+	}
+}
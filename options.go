@@ -1,8 +1,42 @@
 package glhf
 
+import (
+	"log/slog"
+	"time"
+)
+
 type opts struct {
-	defaultContentType string
-	verbose            bool
+	defaultContentType   string
+	verbose              bool
+	compressors          []Compressor
+	compressionThreshold int
+	compressibleTypes    []string
+	maxBodySize          int64
+	etagMode             ETagMode
+	cachePolicy          *CachePolicy
+	validators           ValidatorFunc
+	cacheStore           CacheStore
+	idempotencyStore     IdempotencyStore
+	idempotencyTTL       time.Duration
+	preconditionRequired bool
+	logger               *slog.Logger
+	logBodies            bool
+	route                string
+	observers            []Observer
+	requestID            bool
+	timeout              time.Duration
+	authenticators       []Authenticator
+	principalPolicies    []principalPolicy
+	policies             []any
+	cors                 *CORSConfig
+	rateLimits           []RateLimit
+	maxInFlight          int
+	csrf                 *CSRFConfig
+	hardening            *Hardening
+	decodePolicy         DecodePolicy
+	bodyMode             *BodyMode
+	responseHooks        []ResponseHook
+	signatures           *VerifyConfig
 }
 
 type Options interface {
@@ -30,15 +64,294 @@ func WithDefaultContentType(contentType string) Options {
 	})
 }
 
+// WithVerbose adds the error message as the detail of problem details error bodies.
 func WithVerbose(b bool) Options {
 	return newFuncOption(func(o *opts) {
 		o.verbose = b
 	})
 }
 
+// WithCompression enables response compression negotiated from the Accept-Encoding header.
+// Compressors are preferred in the given order when the client weighs them equally.
+// If no compressors are supplied, gzip and deflate are used.
+func WithCompression(compressors ...Compressor) Options {
+	return newFuncOption(func(o *opts) {
+		if len(compressors) == 0 {
+			compressors = defaultCompressors
+		}
+		o.compressors = compressors
+	})
+}
+
+// WithCompressionThreshold sets the minimum response body size, in bytes, that is compressed. Defaults to 1024.
+func WithCompressionThreshold(n int) Options {
+	return newFuncOption(func(o *opts) {
+		o.compressionThreshold = n
+	})
+}
+
+// WithCompressibleTypes sets the response media types that are compressed. Patterns are matched with path.Match,
+// i.e "text/*" or "application/*+json". Defaults to text, JSON, XML and JavaScript media types.
+func WithCompressibleTypes(patterns ...string) Options {
+	return newFuncOption(func(o *opts) {
+		o.compressibleTypes = patterns
+	})
+}
+
+// WithMaxBodySize sets the maximum size, in bytes, of a request body after any Content-Encoding is decoded.
+// Larger bodies are rejected with 413. A size of zero or less disables the limit. Defaults to 32MiB.
+func WithMaxBodySize(n int64) Options {
+	return newFuncOption(func(o *opts) {
+		o.maxBodySize = n
+	})
+}
+
+// WithETag computes an ETag from the encoded body of successful GET and HEAD responses that do not set one,
+// and answers matching If-None-Match requests with 304 Not Modified.
+func WithETag(mode ETagMode) Options {
+	return newFuncOption(func(o *opts) {
+		o.etagMode = mode
+	})
+}
+
+// WithCachePolicy sets the Cache-Control header of successful GET and HEAD responses.
+func WithCachePolicy(policy CachePolicy) Options {
+	return newFuncOption(func(o *opts) {
+		o.cachePolicy = &policy
+	})
+}
+
+// WithValidators evaluates If-Match, If-None-Match, If-Modified-Since and If-Unmodified-Since against the
+// validators returned by fn before the handler is called. Failed preconditions are answered with 304 Not Modified
+// for GET and HEAD requests and 412 Precondition Failed otherwise. After a successful Post, Put, Patch or Delete,
+// fn is called again to set the ETag of the modified resource if the handler did not set one.
+func WithValidators(fn ValidatorFunc) Options {
+	return newFuncOption(func(o *opts) {
+		o.validators = fn
+	})
+}
+
+// WithResponseCache caches encoded responses of a Get handler in store. Responses are cached for their Cache-Control
+// s-maxage or max-age and may be served stale while they are revalidated for the stale-while-revalidate duration.
+// Concurrent requests for a missing response only call the handler once. Responses setting cookies are not cached.
+// Other HTTP methods ignore this option.
+func WithResponseCache(store CacheStore) Options {
+	return newFuncOption(func(o *opts) {
+		o.cacheStore = store
+	})
+}
+
+// WithIdempotency stores the first response of Post and Patch requests made with an Idempotency-Key header for ttl,
+// 24 hours if ttl is zero. Retries with the same key and body receive the stored response, retries with a different
+// body receive 422 and retries made while the first request is in flight receive 409. Keys are scoped to the
+// authenticated principal. Other HTTP methods ignore this option.
+func WithIdempotency(store IdempotencyStore, ttl time.Duration) Options {
+	return newFuncOption(func(o *opts) {
+		o.idempotencyStore = store
+		o.idempotencyTTL = ttl
+	})
+}
+
+// WithPreconditionRequired rejects Put, Patch and Delete requests without an If-Match header with 428 Precondition
+// Required, protecting resources from lost updates. If-Unmodified-Since is accepted instead of If-Match if WithValidators
+// is set, as it is evaluated against the validators' Last-Modified time.
+func WithPreconditionRequired(b bool) Options {
+	return newFuncOption(func(o *opts) {
+		o.preconditionRequired = b
+	})
+}
+
+// WithLogger emits one structured log record per request with the method, route, status, request and response
+// content-types and sizes, duration and any decode or encode error.
+func WithLogger(logger *slog.Logger) Options {
+	return newFuncOption(func(o *opts) {
+		o.logger = logger
+	})
+}
+
+// WithBodyLogging adds the decoded request body and the response body to the request's log record.
+// Fields tagged with `glhf:"redact"` are replaced with [REDACTED].
+func WithBodyLogging(b bool) Options {
+	return newFuncOption(func(o *opts) {
+		o.logBodies = b
+	})
+}
+
+// WithRoute sets the route name used when reporting the request, i.e "/todo/{id}". Handlers registered with a Router
+// default to their pattern, other handlers to UnknownRoute.
+func WithRoute(route string) Options {
+	return newFuncOption(func(o *opts) {
+		o.route = route
+	})
+}
+
+// WithObserver reports the requests and pipeline phases of the handler to o, i.e for tracing or metrics.
+// Observers are called in the order they are added.
+func WithObserver(observer Observer) Options {
+	return newFuncOption(func(o *opts) {
+		o.observers = append(o.observers, observer)
+	})
+}
+
+// WithRequestID identifies each request by its X-Request-ID header, the trace-id of its traceparent header,
+// or a generated ID. The ID is available from Request.ID, echoed in the X-Request-ID response header and included
+// in error bodies and log records.
+func WithRequestID(b bool) Options {
+	return newFuncOption(func(o *opts) {
+		o.requestID = b
+	})
+}
+
+// WithTimeout calls the handler with a context that is canceled after d. If the handler has not returned by then,
+// the request fails with 503 Service Unavailable and the handler's late response is discarded. Requests whose client
+// disconnects while the handler runs are reported to the logger and observers with status 499, nothing is written.
+// With WithMaxInFlight, a handler that outlives its timeout holds its slot until it returns.
+func WithTimeout(d time.Duration) Options {
+	return newFuncOption(func(o *opts) {
+		o.timeout = d
+	})
+}
+
+// WithAuth authenticates requests before their body is read. Authenticators are tried in order, the first one
+// finding credentials in the request authenticates it and its principal is available from Request.Principal.
+// Requests without credentials, or with invalid credentials, fail with 401 Unauthorized and a WWW-Authenticate challenge.
+func WithAuth(authenticators ...Authenticator) Options {
+	return newFuncOption(func(o *opts) {
+		o.authenticators = authenticators
+	})
+}
+
+// WithScopes forbids requests whose principal is not granted all scopes with 403 Forbidden, or 401 Unauthorized
+// if the request has no principal.
+// The principal must implement ScopedPrincipal. Scopes add to the scopes required by earlier options, i.e of a router group.
+func WithScopes(scopes ...string) Options {
+	return newFuncOption(func(o *opts) {
+		o.principalPolicies = append(o.principalPolicies, requireScopes(scopes))
+	})
+}
+
+// WithRoles forbids requests whose principal has none of roles with 403 Forbidden, or 401 Unauthorized if the
+// request has no principal.
+// The principal must implement RolePrincipal. Each WithRoles option must be satisfied.
+func WithRoles(roles ...string) Options {
+	return newFuncOption(func(o *opts) {
+		o.principalPolicies = append(o.principalPolicies, requireRoles(roles))
+	})
+}
+
+// WithPolicy forbids requests rejected by policy with 403 Forbidden, or 401 Unauthorized if the request has no
+// principal. Policies are evaluated once the request body
+// is decoded, before the handler is called. The handler's request body must be I, it panics otherwise.
+func WithPolicy[I Body](policy Policy[I]) Options {
+	return newFuncOption(func(o *opts) {
+		o.policies = append(o.policies, policy)
+	})
+}
+
+// WithCORS enables Cross-Origin Resource Sharing. Preflight requests are answered with the handler's method, or the
+// methods registered for the path when the handler is registered with a Router. Responses to allowed origins carry
+// the Access-Control-* headers and every response varies by Origin. It panics if the "*" origin is combined with
+// AllowCredentials, which would let any site make credentialed requests.
+func WithCORS(config CORSConfig) Options {
+	if config.AllowCredentials && containsString(config.AllowedOrigins, "*") {
+		panic("glhf: cors credentials can not be allowed for any origin")
+	}
+	return newFuncOption(func(o *opts) {
+		o.cors = &config
+	})
+}
+
+// WithRateLimit limits requests with a token bucket per key, i.e per client IP, API key or principal.
+// Responses carry the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers and
+// requests exceeding the limit fail with 429 Too Many Requests and a Retry-After header. Every limit must allow
+// the request, the rate limit is enforced after authentication and before the request body is read. Requests
+// failing authentication or signature verification are charged too, and fail with 429 once limited. A request
+// exceeding one limit is refunded the tokens it took from the others if their store is a RateLimitRefunder.
+// Handlers panic if Limit or Period are not positive.
+func WithRateLimit(limit RateLimit) Options {
+	return newFuncOption(func(o *opts) {
+		o.rateLimits = append(o.rateLimits, limit)
+	})
+}
+
+// WithMaxInFlight sheds load by failing requests with 503 Service Unavailable while n requests are handled.
+// Each handler counts its own requests.
+func WithMaxInFlight(n int) Options {
+	return newFuncOption(func(o *opts) {
+		o.maxInFlight = n
+	})
+}
+
+// WithCSRF enables Cross-Site Request Forgery protection. Unsafe requests, POST, PUT, PATCH and DELETE, fail with
+// 403 Forbidden if their Sec-Fetch-Site or Origin header shows a cross-origin request, or if they do not submit the
+// token of their double-submit cookie, or their session, in the X-CSRF-Token header or a form field. Requests
+// authenticated by a JWT authenticator of WithAuth are exempt, an Authorization header alone is not. The token is
+// available to handlers with Request.CSRFToken.
+func WithCSRF(config CSRFConfig) Options {
+	return newFuncOption(func(o *opts) {
+		o.csrf = &config
+	})
+}
+
+// WithHardening sets the X-Content-Type-Options: nosniff and Referrer-Policy headers on every response,
+// Strict-Transport-Security on TLS requests and a Content-Security-Policy on HTML responses. Request bodies of
+// JSON media types, including +json types such as application/merge-patch+json, with duplicate keys, trailing data
+// after the JSON value or values nested deeper than the maximum depth are rejected with 400 Bad Request. Fields
+// unknown to the request body are rejected too by the built-in JSON and JSON Merge Patch codecs, unless
+// WithDecodePolicy sets how unknown fields are handled. Codecs registered for other +json types decode as they do.
+func WithHardening(h Hardening) Options {
+	return newFuncOption(func(o *opts) {
+		o.hardening = h.withDefaults()
+	})
+}
+
+// WithDecodePolicy configures how request bodies are decoded: unknown fields of JSON and proto bodies, UseNumber,
+// case-sensitive matching of JSON keys and empty bodies, see StrictDecoding and LenientDecoding. By default JSON
+// unknown fields are discarded, proto unknown fields are kept, JSON keys match case-insensitively and handlers that
+// require a body fail with 400 Bad Request if it is empty.
+func WithDecodePolicy(policy DecodePolicy) Options {
+	return newFuncOption(func(o *opts) {
+		o.decodePolicy = policy
+	})
+}
+
+// WithBody overrides whether the handler's request body is ignored, optional, required or forbidden. By default
+// Get ignores the body, Post and Delete accept an optional body and Put and Patch require one.
+func WithBody(mode BodyMode) Options {
+	return newFuncOption(func(o *opts) {
+		o.bodyMode = &mode
+	})
+}
+
+// WithResponseHook calls hooks, in order, once the response body is encoded and compressed, before the status is
+// written, i.e ContentLengthHook or ContentDigestHook. Hooks also run for error responses, a failing hook fails the
+// response with 500 Internal Server Error. Seekable bodies are buffered instead of streamed so hooks see the full
+// body, including responses served from the response cache. Range requests and 304 Not Modified responses do not
+// run hooks.
+func WithResponseHook(hooks ...ResponseHook) Options {
+	return newFuncOption(func(o *opts) {
+		o.responseHooks = append(o.responseHooks, hooks...)
+	})
+}
+
+// WithSignatureVerification requires requests to carry an HTTP message signature, RFC 9421, made by one of the
+// configured keys. The signature and the Content-Digest of the body are verified before authentication and before
+// the body is decoded. Invalid signatures fail with 401 Unauthorized, bodies that do not match their digest with
+// 400 Bad Request. Signatures must cover @method and @target-uri and are accepted for VerifyConfig.MaxAge, five
+// minutes by default. The key ID of the signature is available with ContextSignatureKeyID. Responses are signed with
+// SignResponseHook.
+func WithSignatureVerification(config VerifyConfig) Options {
+	return newFuncOption(func(o *opts) {
+		o.signatures = &config
+	})
+}
+
 func defaultOptions() *opts {
 	return &opts{
-		defaultContentType: ContentJSON,
-		verbose:            false,
+		defaultContentType:   ContentJSON,
+		verbose:              false,
+		compressionThreshold: defaultCompressionThreshold,
+		compressibleTypes:    defaultCompressibleTypes,
+		maxBodySize:          defaultMaxBodySize,
 	}
 }
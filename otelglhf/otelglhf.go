@@ -0,0 +1,286 @@
+// Package otelglhf instruments glhf handlers with OpenTelemetry tracing and metrics.
+//
+// Each request is traced with a server span named after its method and route, with child spans for the
+// decode, handler and encode phases of the glhf pipeline. Request duration and body sizes are recorded as
+// histograms and failed decodes and encodes are counted by content-type and route. Requests to handlers without a
+// route, see glhf.UnknownRoute, are recorded without the http.route attribute. Methods and content-types a client
+// controls are recorded as _OTHER unless they are known HTTP methods or media types registered with a glhf codec.
+package otelglhf
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/VauntDev/glhf"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// instrumentationName identifies the tracer and meter of the instrumentation.
+	instrumentationName = "github.com/VauntDev/glhf/otelglhf"
+
+	// CodecErrorsName is the name of the counter of failed request decodes and response encodes.
+	CodecErrorsName = "glhf.codec.errors"
+
+	// PhaseKey is the attribute key of the glhf pipeline phase.
+	PhaseKey = attribute.Key("glhf.phase")
+	// ContentTypeKey is the attribute key of the content-type a codec error occurred with.
+	ContentTypeKey = attribute.Key("glhf.content_type")
+	// NegotiationKey is the attribute key of how the response content-type was chosen.
+	NegotiationKey = attribute.Key("glhf.negotiation")
+
+	// otherValue replaces client controlled attribute values that are not known, bounding metric cardinality.
+	otherValue = "_OTHER"
+)
+
+// knownMethods are the HTTP methods recorded as they are, others are recorded as _OTHER.
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Observer is a glhf.Observer reporting requests to OpenTelemetry.
+type Observer struct {
+	tracer       trace.Tracer
+	propagator   propagation.TextMapPropagator
+	duration     metric.Float64Histogram
+	requestSize  metric.Int64Histogram
+	responseSize metric.Int64Histogram
+	codecErrors  metric.Int64Counter
+}
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// Option configures an Observer.
+type Option func(*config)
+
+// WithTracerProvider sets the TracerProvider spans are created with. Defaults to the global TracerProvider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the MeterProvider metrics are recorded with. Defaults to the global MeterProvider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithPropagator sets the propagator used to extract the parent span from request headers.
+// Defaults to the global TextMapPropagator.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// New returns an Observer, add it to a handler with glhf.WithObserver.
+func New(options ...Option) (*Observer, error) {
+	c := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+
+	meter := c.meterProvider.Meter(instrumentationName)
+	o := &Observer{
+		tracer:     c.tracerProvider.Tracer(instrumentationName),
+		propagator: c.propagator,
+	}
+
+	var err error
+	if o.duration, err = meter.Float64Histogram(
+		semconv.HTTPServerRequestDurationName,
+		metric.WithUnit(semconv.HTTPServerRequestDurationUnit),
+		metric.WithDescription(semconv.HTTPServerRequestDurationDescription),
+	); err != nil {
+		return nil, err
+	}
+	if o.requestSize, err = meter.Int64Histogram(
+		semconv.HTTPServerRequestBodySizeName,
+		metric.WithUnit(semconv.HTTPServerRequestBodySizeUnit),
+		metric.WithDescription(semconv.HTTPServerRequestBodySizeDescription),
+	); err != nil {
+		return nil, err
+	}
+	if o.responseSize, err = meter.Int64Histogram(
+		semconv.HTTPServerResponseBodySizeName,
+		metric.WithUnit(semconv.HTTPServerResponseBodySizeUnit),
+		metric.WithDescription(semconv.HTTPServerResponseBodySizeDescription),
+	); err != nil {
+		return nil, err
+	}
+	if o.codecErrors, err = meter.Int64Counter(
+		CodecErrorsName,
+		metric.WithUnit("{error}"),
+		metric.WithDescription("Number of request bodies that failed to decode and response bodies that failed to encode."),
+	); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// StartRequest starts the server span of the request, continuing the trace propagated in its headers.
+func (o *Observer) StartRequest(r *http.Request, route string) *http.Request {
+	ctx := o.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	method := requestMethod(r.Method)
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(method),
+		semconv.URLPath(r.URL.Path),
+		semconv.URLScheme(scheme(r)),
+		semconv.ServerAddress(r.Host),
+		semconv.NetworkProtocolVersion(strconv.Itoa(r.ProtoMajor) + "." + strconv.Itoa(r.ProtoMinor)),
+	}
+	if ua := r.UserAgent(); len(ua) > 0 {
+		attrs = append(attrs, semconv.UserAgentOriginal(ua))
+	}
+	if method == otherValue {
+		attrs = append(attrs, semconv.HTTPRequestMethodOriginal(r.Method))
+	}
+	if len(r.RemoteAddr) > 0 {
+		host, port, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		attrs = append(attrs, semconv.ClientAddress(host))
+		if p, err := strconv.Atoi(port); err == nil {
+			attrs = append(attrs, semconv.ClientPort(p))
+		}
+	}
+
+	// spans of unrouted requests are named after their method only, as the semantic conventions require
+	name := method
+	if method == otherValue {
+		name = "HTTP"
+	}
+	if route != glhf.UnknownRoute {
+		attrs = append(attrs, semconv.HTTPRoute(route))
+		name += " " + route
+	}
+
+	ctx, _ = o.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+	return r.WithContext(ctx)
+}
+
+// StartPhase starts a child span of the request span for the phase.
+func (o *Observer) StartPhase(ctx context.Context, phase glhf.Phase) (context.Context, func(err error)) {
+	ctx, span := o.tracer.Start(ctx, "glhf."+string(phase),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(PhaseKey.String(string(phase))),
+	)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// EndRequest ends the request span and records the request's metrics.
+func (o *Observer) EndRequest(ctx context.Context, info glhf.RequestInfo) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		semconv.HTTPResponseStatusCode(info.StatusCode),
+		semconv.HTTPRequestBodySize(info.RequestSize),
+		semconv.HTTPResponseBodySize(info.ResponseSize),
+	)
+	if len(info.Negotiation) > 0 {
+		span.SetAttributes(NegotiationKey.String(string(info.Negotiation)))
+	}
+	// only server errors mark the server span as failed
+	if info.StatusCode >= http.StatusInternalServerError {
+		span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(info.StatusCode)))
+		if info.Err != nil {
+			span.SetStatus(codes.Error, info.Err.Error())
+		} else {
+			span.SetStatus(codes.Error, "")
+		}
+	}
+	span.End()
+
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(requestMethod(info.Method)),
+		semconv.HTTPResponseStatusCode(info.StatusCode),
+	}
+	attrs = append(attrs, routeAttrs(info.Route)...)
+	if info.StatusCode >= http.StatusInternalServerError {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(info.StatusCode)))
+	}
+	set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+
+	o.duration.Record(ctx, info.Duration.Seconds(), set)
+	o.requestSize.Record(ctx, int64(info.RequestSize), set)
+	o.responseSize.Record(ctx, int64(info.ResponseSize), set)
+
+	if info.Err != nil && (info.ErrPhase == glhf.PhaseDecode || info.ErrPhase == glhf.PhaseEncode) {
+		contentType := info.RequestContentType
+		if info.ErrPhase == glhf.PhaseEncode {
+			contentType = info.ResponseContentType
+		}
+		errAttrs := append(routeAttrs(info.Route),
+			PhaseKey.String(string(info.ErrPhase)),
+			ContentTypeKey.String(registeredMediaType(contentType)),
+		)
+		o.codecErrors.Add(ctx, 1, metric.WithAttributes(errAttrs...))
+	}
+}
+
+// routeAttrs returns the http.route attribute of route, none if the request was not routed.
+func routeAttrs(route string) []attribute.KeyValue {
+	if route == glhf.UnknownRoute {
+		return nil
+	}
+	return []attribute.KeyValue{semconv.HTTPRoute(route)}
+}
+
+// requestMethod returns method if it is a known HTTP method, otherwise _OTHER.
+func requestMethod(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return otherValue
+}
+
+// registeredMediaType returns the media type of contentType if a glhf codec is registered for it, otherwise _OTHER.
+func registeredMediaType(contentType string) string {
+	if mediaType, _, ok := glhf.LookupCodec(contentType); ok {
+		return mediaType
+	}
+	return otherValue
+}
+
+func scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,235 @@
+package otelglhf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VauntDev/glhf"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+type todo struct {
+	Title string `json:"title"`
+}
+
+func newTestObserver(t *testing.T) (*Observer, *tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	o, err := New(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		WithPropagator(propagation.TraceContext{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o, spans, reader
+}
+
+func TestObserverSpans(t *testing.T) {
+	o, spans, _ := newTestObserver(t)
+	handler := glhf.Post(func(r *glhf.Request[todo], w *glhf.Response[todo]) {
+		w.SetBody(r.Body())
+	}, glhf.WithObserver(o), glhf.WithRoute("/todo"))
+
+	r := httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(`{"title":"glhf"}`))
+	r.Header.Set(glhf.ContentType, glhf.ContentJSON)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler(httptest.NewRecorder(), r)
+
+	ended := spans.Ended()
+	if len(ended) != 4 {
+		t.Fatalf("found %d spans; expected 4", len(ended))
+	}
+
+	server := ended[len(ended)-1]
+	if server.Name() != "POST /todo" {
+		t.Errorf("span name = %s; expected POST /todo", server.Name())
+	}
+	if server.Parent().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("span did not continue the propagated trace")
+	}
+	attrs := attribute.NewSet(server.Attributes()...)
+	if v, _ := attrs.Value(semconv.HTTPResponseStatusCodeKey); v.AsInt64() != http.StatusOK {
+		t.Errorf("status code attribute = %d; expected %d", v.AsInt64(), http.StatusOK)
+	}
+	if v, _ := attrs.Value(semconv.HTTPRouteKey); v.AsString() != "/todo" {
+		t.Errorf("route attribute = %s; expected /todo", v.AsString())
+	}
+
+	for i, name := range []string{"glhf.decode", "glhf.handler", "glhf.encode"} {
+		if ended[i].Name() != name {
+			t.Errorf("span %d = %s; expected %s", i, ended[i].Name(), name)
+		}
+		if ended[i].Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the server span", name)
+		}
+	}
+}
+
+func TestObserverUnknownRoute(t *testing.T) {
+	o, spans, reader := newTestObserver(t)
+	handler := glhf.Get(func(r *glhf.Request[glhf.EmptyBody], w *glhf.Response[todo]) {
+		w.SetBody(&todo{Title: "glhf"})
+	}, glhf.WithObserver(o))
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/todo/42", nil))
+
+	ended := spans.Ended()
+	server := ended[len(ended)-1]
+	if server.Name() != http.MethodGet {
+		t.Errorf("span name = %s; expected %s", server.Name(), http.MethodGet)
+	}
+	attrs := attribute.NewSet(server.Attributes()...)
+	if v, ok := attrs.Value(semconv.HTTPRouteKey); ok {
+		t.Errorf("route attribute = %s; expected none", v.AsString())
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			duration, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok {
+				continue
+			}
+			for _, dp := range duration.DataPoints {
+				if v, ok := dp.Attributes.Value(semconv.HTTPRouteKey); ok {
+					t.Errorf("%s route attribute = %s; expected none", m.Name, v.AsString())
+				}
+			}
+		}
+	}
+}
+
+func TestObserverClientValues(t *testing.T) {
+	o, spans, reader := newTestObserver(t)
+	handler := glhf.Post(func(r *glhf.Request[todo], w *glhf.Response[todo]) {
+		w.SetBody(r.Body())
+	}, glhf.WithObserver(o), glhf.WithRoute("/todo"))
+
+	r := httptest.NewRequest("BREW", "/todo", nil)
+	handler(httptest.NewRecorder(), r)
+	r = httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(`{}`))
+	r.Header.Set(glhf.ContentType, "application/x-client-chosen")
+	handler(httptest.NewRecorder(), r)
+
+	ended := spans.Ended()
+	server := ended[0]
+	if server.Name() != "HTTP /todo" {
+		t.Errorf("span name = %s; expected HTTP /todo", server.Name())
+	}
+	attrs := attribute.NewSet(server.Attributes()...)
+	if v, _ := attrs.Value(semconv.HTTPRequestMethodKey); v.AsString() != "_OTHER" {
+		t.Errorf("method attribute = %s; expected _OTHER", v.AsString())
+	}
+	if v, _ := attrs.Value(semconv.HTTPRequestMethodOriginalKey); v.AsString() != "BREW" {
+		t.Errorf("original method attribute = %s; expected BREW", v.AsString())
+	}
+	if v, _ := attrs.Value(semconv.ClientAddressKey); v.AsString() != "192.0.2.1" {
+		t.Errorf("client address attribute = %s; expected 192.0.2.1", v.AsString())
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != CodecErrorsName {
+				continue
+			}
+			dp := m.Data.(metricdata.Sum[int64]).DataPoints[0]
+			if v, _ := dp.Attributes.Value(ContentTypeKey); v.AsString() != "_OTHER" {
+				t.Errorf("codec error content-type = %s; expected _OTHER", v.AsString())
+			}
+			return
+		}
+	}
+	t.Errorf("missing %s counter", CodecErrorsName)
+}
+
+func TestObserverMetrics(t *testing.T) {
+	o, spans, reader := newTestObserver(t)
+	handler := glhf.Post(func(r *glhf.Request[todo], w *glhf.Response[todo]) {
+		w.SetBody(r.Body())
+	}, glhf.WithObserver(o), glhf.WithRoute("/todo"))
+
+	r := httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(`{"title":"glhf"}`))
+	r.Header.Set(glhf.ContentType, glhf.ContentJSON)
+	handler(httptest.NewRecorder(), r)
+
+	r = httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(`{`))
+	r.Header.Set(glhf.ContentType, glhf.ContentJSON)
+	handler(httptest.NewRecorder(), r)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = m.Data
+		}
+	}
+
+	duration, ok := found[semconv.HTTPServerRequestDurationName].(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("missing %s histogram", semconv.HTTPServerRequestDurationName)
+	}
+	var count uint64
+	for _, dp := range duration.DataPoints {
+		count += dp.Count
+	}
+	if count != 2 {
+		t.Errorf("recorded %d durations; expected 2", count)
+	}
+
+	requestSize, ok := found[semconv.HTTPServerRequestBodySizeName].(metricdata.Histogram[int64])
+	if !ok {
+		t.Fatalf("missing %s histogram", semconv.HTTPServerRequestBodySizeName)
+	}
+	var total int64
+	for _, dp := range requestSize.DataPoints {
+		total += dp.Sum
+	}
+	if total != 17 {
+		t.Errorf("request body size sum = %d; expected 17", total)
+	}
+
+	codecErrors, ok := found[CodecErrorsName].(metricdata.Sum[int64])
+	if !ok || len(codecErrors.DataPoints) != 1 {
+		t.Fatalf("missing %s counter", CodecErrorsName)
+	}
+	dp := codecErrors.DataPoints[0]
+	if v, _ := dp.Attributes.Value(ContentTypeKey); dp.Value != 1 || v.AsString() != glhf.ContentJSON {
+		t.Errorf("codec errors = %d with content-type %s; expected 1 with %s", dp.Value, v.AsString(), glhf.ContentJSON)
+	}
+	if v, _ := dp.Attributes.Value(PhaseKey); v.AsString() != string(glhf.PhaseDecode) {
+		t.Errorf("codec error phase = %s; expected %s", v.AsString(), glhf.PhaseDecode)
+	}
+
+	// the failed decode is recorded on its phase span
+	for _, span := range spans.Ended() {
+		if span.Name() == "glhf.decode" && span.Status().Code == codes.Error {
+			return
+		}
+	}
+	t.Error("decode span of the failed request is not marked as failed")
+}
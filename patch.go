@@ -0,0 +1,536 @@
+package glhf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const (
+	// ContentMergePatch header value for JSON Merge Patch documents (RFC 7396).
+	ContentMergePatch = "application/merge-patch+json"
+	// ContentJSONPatch header value for JSON Patch documents (RFC 6902).
+	ContentJSONPatch = "application/json-patch+json"
+)
+
+func init() {
+	RegisterCodec(patchCodec{mergePatch: true}, ContentMergePatch)
+	RegisterCodec(patchCodec{}, ContentJSONPatch)
+}
+
+// PatchOperation is a single JSON Patch operation (RFC 6902).
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// PatchDocument is a JSON Merge Patch or JSON Patch request body. Use it as the request body of a Patch handler
+// to distinguish absent fields from fields set to their zero value, and apply it to the current resource with Apply.
+// A PatchDocument sent with the application/json content-type is treated as a JSON Merge Patch.
+type PatchDocument struct {
+	merge      any
+	operations []PatchOperation
+	mergePatch bool
+}
+
+// IsMergePatch reports whether the document is a JSON Merge Patch, otherwise it is a JSON Patch.
+func (p *PatchDocument) IsMergePatch() bool {
+	return p.mergePatch
+}
+
+// Operations returns the operations of a JSON Patch document.
+func (p *PatchDocument) Operations() []PatchOperation {
+	return p.operations
+}
+
+// UnmarshalJSON decodes a JSON Merge Patch document.
+func (p *PatchDocument) UnmarshalJSON(b []byte) error {
+	return p.unmarshal(b, true)
+}
+
+func (p *PatchDocument) unmarshal(b []byte, mergePatch bool) error {
+	*p = PatchDocument{mergePatch: mergePatch}
+	if mergePatch {
+		return decodeJSON(b, &p.merge)
+	}
+	if err := json.Unmarshal(b, &p.operations); err != nil {
+		return err
+	}
+	for _, op := range p.operations {
+		if err := checkOperation(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Paths returns the sorted, dot separated, paths of the fields modified by the document, i.e "item.name".
+// JSON Patch paths end before the first array index, an operation on /tags/0 or /tags/- modifies the field tags.
+func (p *PatchDocument) Paths() []string {
+	seen := map[string]bool{}
+	if p.mergePatch {
+		collectPaths(p.merge, "", seen)
+	} else {
+		for _, op := range p.operations {
+			if len(op.Path) == 0 {
+				// the whole document is replaced, every field of the new value is modified
+				var value map[string]json.RawMessage
+				if json.Unmarshal(op.Value, &value) == nil {
+					for k := range value {
+						seen[k] = true
+					}
+				}
+				continue
+			}
+			seen[pointerToPath(op.Path)] = true
+			if op.Op == "move" {
+				seen[pointerToPath(op.From)] = true
+			}
+		}
+	}
+	delete(seen, "")
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// FieldMask returns the paths modified by the document as a protobuf FieldMask.
+func (p *PatchDocument) FieldMask() *fieldmaskpb.FieldMask {
+	return &fieldmaskpb.FieldMask{Paths: p.Paths()}
+}
+
+// Apply applies the document to v, a pointer to the current resource. Proto messages are converted with protojson,
+// other values with encoding/json. v is left unchanged if the patch fails.
+func (p *PatchDocument) Apply(v any) error {
+	msg, isProto := v.(proto.Message)
+
+	var (
+		b   []byte
+		err error
+	)
+	if isProto {
+		b, err = protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	} else {
+		b, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	var doc any
+	if err := decodeJSON(b, &doc); err != nil {
+		return err
+	}
+
+	if p.mergePatch {
+		doc = mergePatch(doc, p.merge)
+	} else {
+		for _, op := range p.operations {
+			if doc, err = applyOperation(doc, op); err != nil {
+				return err
+			}
+		}
+	}
+
+	if b, err = json.Marshal(doc); err != nil {
+		return err
+	}
+
+	// decode into a new value so removed fields are cleared, v is only replaced once the patched document decoded
+	if isProto {
+		patched := msg.ProtoReflect().New().Interface()
+		if err := protojson.Unmarshal(b, patched); err != nil {
+			return err
+		}
+		proto.Reset(msg)
+		proto.Merge(msg, patched)
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return ErrPatchDocument
+	}
+	patched := reflect.New(rv.Elem().Type())
+	if err := json.Unmarshal(b, patched.Interface()); err != nil {
+		return err
+	}
+	rv.Elem().Set(patched.Elem())
+	return nil
+}
+
+// ApplyFieldMask copies the fields of src listed in mask to dst, both messages must be of the same type.
+// Fields listed in mask but not set in src are cleared in dst.
+func ApplyFieldMask(dst proto.Message, src proto.Message, mask *fieldmaskpb.FieldMask) error {
+	if dst.ProtoReflect().Descriptor() != src.ProtoReflect().Descriptor() {
+		return ErrPatchDocument
+	}
+	if !mask.IsValid(dst) {
+		return ErrPatchPath
+	}
+
+	for _, path := range mask.GetPaths() {
+		d, s := dst.ProtoReflect(), src.ProtoReflect()
+		names := strings.Split(path, ".")
+		for i, name := range names {
+			fd := d.Descriptor().Fields().ByName(protoreflect.Name(name))
+			if i == len(names)-1 {
+				if s.Has(fd) {
+					d.Set(fd, s.Get(fd))
+				} else {
+					d.Clear(fd)
+				}
+				break
+			}
+			// descend into the nested message, creating it in dst if needed
+			if !s.Has(fd) {
+				d.Clear(fd)
+				break
+			}
+			d, s = d.Mutable(fd).Message(), s.Get(fd).Message()
+		}
+	}
+	return nil
+}
+
+// patchCodec decodes JSON Merge Patch and JSON Patch documents. Bodies other than PatchDocument
+// are decoded as JSON.
+type patchCodec struct {
+	mergePatch bool
+}
+
+func (patchCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c patchCodec) Unmarshal(data []byte, v any) error {
+	if doc, ok := v.(*PatchDocument); ok {
+		return doc.unmarshal(data, c.mergePatch)
+	}
+	if !c.mergePatch {
+		return ErrPatchDocument
+	}
+	return json.Unmarshal(data, v)
+}
+
+// decodeJSON decodes b into v keeping numbers as json.Number so they are not rounded.
+func decodeJSON(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// mergePatch applies a JSON Merge Patch to target, RFC 7396 section 2.
+func mergePatch(target any, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = mergePatch(targetObj[k], v)
+	}
+	return targetObj
+}
+
+// collectPaths adds the paths of the leaf values of a merge patch to seen.
+func collectPaths(v any, prefix string, seen map[string]bool) {
+	obj, ok := v.(map[string]any)
+	if !ok || len(obj) == 0 {
+		seen[prefix] = true
+		return
+	}
+	for k, child := range obj {
+		path := k
+		if len(prefix) > 0 {
+			path = prefix + "." + k
+		}
+		collectPaths(child, path, seen)
+	}
+}
+
+// pointerToPath converts a JSON pointer to a dot separated field path, i.e /item/name to item.name. The path ends
+// before the first array index or "-", field masks can not address array elements.
+func pointerToPath(pointer string) string {
+	var fields []string
+	for _, token := range splitPointer(pointer) {
+		if isArrayIndex(token) {
+			break
+		}
+		fields = append(fields, token)
+	}
+	return strings.Join(fields, ".")
+}
+
+// isArrayIndex reports whether a JSON pointer reference token is an array index, RFC 6901 section 4.
+func isArrayIndex(token string) bool {
+	if token == "-" || token == "0" {
+		return true
+	}
+	if len(token) == 0 || token[0] == '0' {
+		return false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPointer returns the unescaped reference tokens of a JSON pointer (RFC 6901).
+func splitPointer(pointer string) []string {
+	if len(pointer) == 0 {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+// checkOperation checks the members an operation requires, RFC 6902 section 4. add, replace and test require a
+// value, a location can not be moved into one of its children.
+func checkOperation(op PatchOperation) error {
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return fmt.Errorf("%w: %s %s has no value", ErrPatchOperation, op.Op, op.Path)
+		}
+	case "move":
+		if isProperPrefix(splitPointer(op.From), splitPointer(op.Path)) {
+			return fmt.Errorf("%w: can not move %s into %s", ErrPatchOperation, op.From, op.Path)
+		}
+	}
+	return nil
+}
+
+// isProperPrefix reports whether the pointer tokens prefix locate a parent of the location of tokens.
+func isProperPrefix(prefix []string, tokens []string) bool {
+	if len(prefix) >= len(tokens) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// applyOperation applies a single JSON Patch operation to doc, RFC 6902 section 4.
+func applyOperation(doc any, op PatchOperation) (any, error) {
+	if err := checkOperation(op); err != nil {
+		return nil, err
+	}
+
+	var value any
+	if len(op.Value) > 0 {
+		if err := decodeJSON(op.Value, &value); err != nil {
+			return nil, err
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return addValue(doc, splitPointer(op.Path), value)
+	case "remove":
+		return removeValue(doc, splitPointer(op.Path))
+	case "replace":
+		// the root pointer replaces the whole document
+		if len(op.Path) == 0 {
+			return value, nil
+		}
+		doc, err := removeValue(doc, splitPointer(op.Path))
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, splitPointer(op.Path), value)
+	case "move", "copy":
+		from, err := getValue(doc, splitPointer(op.From))
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if doc, err = removeValue(doc, splitPointer(op.From)); err != nil {
+				return nil, err
+			}
+		} else {
+			// the copy must not share objects and arrays with its source
+			from = copyValue(from)
+		}
+		return addValue(doc, splitPointer(op.Path), from)
+	case "test":
+		current, err := getValue(doc, splitPointer(op.Path))
+		if err != nil {
+			return nil, err
+		}
+		a, _ := json.Marshal(current)
+		b, _ := json.Marshal(value)
+		if !bytes.Equal(a, b) {
+			return nil, ErrPatchTest
+		}
+		return doc, nil
+	default:
+		return nil, ErrPatchOp
+	}
+}
+
+// copyValue returns a deep copy of a decoded JSON value.
+func copyValue(v any) any {
+	switch node := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(node))
+		for k, v := range node {
+			c[k] = copyValue(v)
+		}
+		return c
+	case []any:
+		c := make([]any, len(node))
+		for i, v := range node {
+			c[i] = copyValue(v)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+func getValue(doc any, tokens []string) (any, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]any:
+			v, ok := node[token]
+			if !ok {
+				return nil, ErrPatchPath
+			}
+			doc = v
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, ErrPatchPath
+			}
+			doc = node[i]
+		default:
+			return nil, ErrPatchPath
+		}
+	}
+	return doc, nil
+}
+
+// addValue returns doc with value added at the location of tokens, array elements are inserted.
+func addValue(doc any, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch node := doc.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, ErrPatchPath
+		}
+		v, err := addValue(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = v
+		return node, nil
+	case []any:
+		if len(rest) == 0 {
+			if token == "-" {
+				return append(node, value), nil
+			}
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i > len(node) {
+				return nil, ErrPatchPath
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(node) {
+			return nil, ErrPatchPath
+		}
+		v, err := addValue(node[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = v
+		return node, nil
+	default:
+		return nil, ErrPatchPath
+	}
+}
+
+// removeValue returns doc with the value at the location of tokens removed.
+func removeValue(doc any, tokens []string) (any, error) {
+	if len(tokens) == 0 {
+		return nil, ErrPatchPath
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if !ok {
+			return nil, ErrPatchPath
+		}
+		if len(rest) == 0 {
+			delete(node, token)
+			return node, nil
+		}
+		v, err := removeValue(child, rest)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = v
+		return node, nil
+	case []any:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(node) {
+			return nil, ErrPatchPath
+		}
+		if len(rest) == 0 {
+			return append(node[:i], node[i+1:]...), nil
+		}
+		v, err := removeValue(node[i], rest)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = v
+		return node, nil
+	default:
+		return nil, ErrPatchPath
+	}
+}
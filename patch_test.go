@@ -0,0 +1,188 @@
+package glhf
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+func TestPatchDocument(t *testing.T) {
+	type item struct {
+		Name    string `json:"name"`
+		Message string `json:"message,omitempty"`
+	}
+	type todo struct {
+		ID    string   `json:"id"`
+		Done  bool     `json:"done"`
+		Tags  []string `json:"tags"`
+		Item  *item    `json:"item,omitempty"`
+		Count int      `json:"count"`
+	}
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		paths       []string
+		expected    todo
+	}{
+		{
+			name:        "merge patch",
+			contentType: ContentMergePatch,
+			body:        `{"done":false,"item":{"message":null,"name":"b"},"count":0}`,
+			paths:       []string{"count", "done", "item.message", "item.name"},
+			expected:    todo{ID: "1", Tags: []string{"a"}, Item: &item{Name: "b"}},
+		},
+		{
+			name:        "json patch",
+			contentType: ContentJSONPatch,
+			body: `[{"op":"test","path":"/id","value":"1"},{"op":"add","path":"/tags/-","value":"b"},` +
+				`{"op":"replace","path":"/item/name","value":"c"},{"op":"remove","path":"/item/message"}]`,
+			paths:    []string{"id", "item.message", "item.name", "tags"},
+			expected: todo{ID: "1", Done: true, Tags: []string{"a", "b"}, Item: &item{Name: "c"}, Count: 2},
+		},
+		{
+			name:        "json patch root",
+			contentType: ContentJSONPatch,
+			body:        `[{"op":"replace","path":"","value":{"id":"2","tags":["c"]}},{"op":"replace","path":"/tags/0","value":"d"}]`,
+			paths:       []string{"id", "tags"},
+			expected:    todo{ID: "2", Tags: []string{"d"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var actual todo
+			handler := Patch(func(r *Request[PatchDocument], w *Response[todo]) {
+				if paths := r.Body().Paths(); !reflect.DeepEqual(paths, testCase.paths) {
+					t.Errorf("Paths() = %v; expected %v", paths, testCase.paths)
+				}
+
+				actual = todo{ID: "1", Done: true, Tags: []string{"a"}, Item: &item{Name: "a", Message: "m"}, Count: 2}
+				if err := r.Body().Apply(&actual); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(testCase.body))
+			r.Header.Set(ContentType, testCase.contentType)
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d; expected %d", w.Code, http.StatusOK)
+			}
+			if !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("patched = %+v; expected %+v", actual, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestApplyFieldMask(t *testing.T) {
+	dst := &typepb.Type{Name: "a", SourceContext: nil, Syntax: typepb.Syntax_SYNTAX_PROTO3}
+	src := &typepb.Type{Name: "b", Syntax: typepb.Syntax_SYNTAX_PROTO2}
+
+	if err := ApplyFieldMask(dst, src, &fieldmaskpb.FieldMask{Paths: []string{"name"}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := &typepb.Type{Name: "b", Syntax: typepb.Syntax_SYNTAX_PROTO3}
+	if !proto.Equal(dst, expected) {
+		t.Errorf("ApplyFieldMask() = %v; expected %v", dst, expected)
+	}
+}
+
+func TestPointerToPath(t *testing.T) {
+	testCases := []struct {
+		pointer  string
+		expected string
+	}{
+		{"", ""},
+		{"/item/name", "item.name"},
+		{"/tags/-", "tags"},
+		{"/tags/0/name", "tags"},
+		{"/items/10", "items"},
+		{"/labels/01", "labels.01"},
+		{"/a~1b/c~0d", "a/b.c~d"},
+	}
+
+	for _, testCase := range testCases {
+		if actual := pointerToPath(testCase.pointer); actual != testCase.expected {
+			t.Errorf("pointerToPath(%q) = %q; expected %q", testCase.pointer, actual, testCase.expected)
+		}
+	}
+}
+
+func TestPatchApplyFailure(t *testing.T) {
+	type todo struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	doc := &PatchDocument{}
+	if err := doc.unmarshal([]byte(`{"age":"three"}`), true); err != nil {
+		t.Fatal(err)
+	}
+	actual := todo{Name: "keep", Age: 3}
+	if err := doc.Apply(&actual); err == nil {
+		t.Fatal("expected the type mismatch to fail")
+	}
+	if expected := (todo{Name: "keep", Age: 3}); actual != expected {
+		t.Errorf("resource = %+v after a failed patch; expected %+v", actual, expected)
+	}
+}
+
+func TestApplyOperation(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ops      string
+		expected string
+		err      error
+	}{
+		{
+			name:     "copy is not shared",
+			ops:      `[{"op":"copy","from":"/a","path":"/b"},{"op":"add","path":"/b/x","value":1}]`,
+			expected: `{"a":{},"b":{"x":1}}`,
+		},
+		{name: "move into child", ops: `[{"op":"move","from":"/a","path":"/a/x"}]`, err: ErrPatchOperation},
+		{name: "move root", ops: `[{"op":"move","from":"","path":"/a/x"}]`, err: ErrPatchOperation},
+		{name: "add without value", ops: `[{"op":"add","path":"/b"}]`, err: ErrPatchOperation},
+		{name: "replace without value", ops: `[{"op":"replace","path":"/a"}]`, err: ErrPatchOperation},
+		{name: "test without value", ops: `[{"op":"test","path":"/a"}]`, err: ErrPatchOperation},
+		{name: "add null", ops: `[{"op":"add","path":"/b","value":null}]`, expected: `{"a":{},"b":null}`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var ops []PatchOperation
+			if err := json.Unmarshal([]byte(testCase.ops), &ops); err != nil {
+				t.Fatal(err)
+			}
+
+			var doc any = map[string]any{"a": map[string]any{}}
+			var err error
+			for _, op := range ops {
+				if doc, err = applyOperation(doc, op); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("err = %v; expected %v", err, testCase.err)
+			}
+			if err != nil {
+				return
+			}
+			if b, _ := json.Marshal(doc); string(b) != testCase.expected {
+				t.Errorf("patched = %s; expected %s", b, testCase.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Package promglhf collects Prometheus metrics for glhf handlers.
+//
+// Requests are counted by route, method and status, their latency and body sizes are observed in histograms,
+// and the outcome of response content-type negotiation is counted by the chosen content-type. Requests to handlers
+// without a route are labelled glhf.UnknownRoute, set routes with glhf.WithRoute or a glhf.Router. Methods other
+// than the standard HTTP methods are labelled _OTHER so clients cannot create series.
+package promglhf
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/VauntDev/glhf"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// otherMethod labels requests whose method is not a known HTTP method.
+const otherMethod = "_OTHER"
+
+// knownMethods are the HTTP methods used as method labels.
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Collector is a glhf.Observer and prometheus.Collector of request metrics.
+type Collector struct {
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+	negotiations *prometheus.CounterVec
+	registry     *prometheus.Registry
+}
+
+type config struct {
+	namespace       string
+	durationBuckets []float64
+	sizeBuckets     []float64
+}
+
+// Option configures a Collector.
+type Option func(*config)
+
+// WithNamespace sets the namespace metric names are prefixed with. Defaults to "glhf".
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithDurationBuckets sets the buckets, in seconds, of the request duration histogram. Defaults to prometheus.DefBuckets.
+func WithDurationBuckets(buckets ...float64) Option {
+	return func(c *config) {
+		c.durationBuckets = buckets
+	}
+}
+
+// WithSizeBuckets sets the buckets, in bytes, of the request and response size histograms.
+// Defaults to powers of 4 from 64B to 16MiB.
+func WithSizeBuckets(buckets ...float64) Option {
+	return func(c *config) {
+		c.sizeBuckets = buckets
+	}
+}
+
+// New returns a Collector, add it to handlers with glhf.WithObserver. The collector is registered with its own
+// registry served by Handler, it can also be registered with another prometheus.Registerer.
+func New(options ...Option) *Collector {
+	c := &config{
+		namespace:       "glhf",
+		durationBuckets: prometheus.DefBuckets,
+		sizeBuckets:     prometheus.ExponentialBuckets(64, 4, 10),
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+
+	collector := &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: c.namespace,
+			Name:      "requests_total",
+			Help:      "Number of requests handled by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: c.namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of requests by route, method and status.",
+			Buckets:   c.durationBuckets,
+		}, []string{"route", "method", "status"}),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: c.namespace,
+			Name:      "request_size_bytes",
+			Help:      "Size of decoded request bodies by route and method.",
+			Buckets:   c.sizeBuckets,
+		}, []string{"route", "method"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: c.namespace,
+			Name:      "response_size_bytes",
+			Help:      "Size of written response bodies by route, method and status.",
+			Buckets:   c.sizeBuckets,
+		}, []string{"route", "method", "status"}),
+		negotiations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: c.namespace,
+			Name:      "negotiations_total",
+			Help:      "Number of encoded responses by route, chosen content-type and negotiation outcome.",
+		}, []string{"route", "content_type", "outcome"}),
+		registry: prometheus.NewRegistry(),
+	}
+	collector.registry.MustRegister(collector)
+	return collector
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.duration.Describe(ch)
+	c.requestSize.Describe(ch)
+	c.responseSize.Describe(ch)
+	c.negotiations.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.duration.Collect(ch)
+	c.requestSize.Collect(ch)
+	c.responseSize.Collect(ch)
+	c.negotiations.Collect(ch)
+}
+
+// Handler returns the http.Handler exposing the collected metrics, i.e on /metrics.
+func (c *Collector) Handler() http.Handler {
+	return promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{})
+}
+
+// StartRequest implements glhf.Observer, requests are recorded once they complete.
+func (c *Collector) StartRequest(r *http.Request, route string) *http.Request {
+	return r
+}
+
+// StartPhase implements glhf.Observer, phases are not recorded.
+func (c *Collector) StartPhase(ctx context.Context, phase glhf.Phase) (context.Context, func(err error)) {
+	return ctx, func(error) {}
+}
+
+// EndRequest records the metrics of a completed request.
+func (c *Collector) EndRequest(ctx context.Context, info glhf.RequestInfo) {
+	status := strconv.Itoa(info.StatusCode)
+	method := info.Method
+	if !knownMethods[method] {
+		method = otherMethod
+	}
+
+	c.requests.WithLabelValues(info.Route, method, status).Inc()
+	c.duration.WithLabelValues(info.Route, method, status).Observe(info.Duration.Seconds())
+	c.requestSize.WithLabelValues(info.Route, method).Observe(float64(info.RequestSize))
+	c.responseSize.WithLabelValues(info.Route, method, status).Observe(float64(info.ResponseSize))
+
+	if len(info.Negotiation) > 0 {
+		contentType := info.ResponseContentType
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			contentType = mediaType
+		}
+		c.negotiations.WithLabelValues(info.Route, contentType, string(info.Negotiation)).Inc()
+	}
+}
@@ -0,0 +1,101 @@
+package promglhf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VauntDev/glhf"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type todo struct {
+	Title string `json:"title"`
+}
+
+func TestCollector(t *testing.T) {
+	c := New()
+	handler := glhf.Get(func(r *glhf.Request[glhf.EmptyBody], w *glhf.Response[todo]) {
+		w.SetBody(&todo{Title: "glhf"})
+	}, glhf.WithObserver(c), glhf.WithRoute("/todo"))
+
+	tests := []struct {
+		accept  string
+		outcome string
+	}{
+		{accept: glhf.ContentJSON, outcome: "accept"},
+		{accept: glhf.ContentCBOR, outcome: "accept"},
+		{accept: "text/csv", outcome: "default"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/todo", nil)
+		r.Header.Set(glhf.Accept, tt.accept)
+		handler(httptest.NewRecorder(), r)
+	}
+
+	if n := testutil.ToFloat64(c.requests.WithLabelValues("/todo", http.MethodGet, "200")); n != 3 {
+		t.Errorf("requests = %v; expected 3", n)
+	}
+	negotiations := []struct {
+		contentType string
+		outcome     string
+		expected    float64
+	}{
+		{contentType: glhf.ContentJSON, outcome: "accept", expected: 1},
+		{contentType: glhf.ContentCBOR, outcome: "accept", expected: 1},
+		{contentType: glhf.ContentJSON, outcome: "default", expected: 1},
+	}
+	for _, tt := range negotiations {
+		if n := testutil.ToFloat64(c.negotiations.WithLabelValues("/todo", tt.contentType, tt.outcome)); n != tt.expected {
+			t.Errorf("negotiations %s %s = %v; expected %v", tt.contentType, tt.outcome, n, tt.expected)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	c.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, name := range []string{"glhf_requests_total", "glhf_request_duration_seconds", "glhf_response_size_bytes", "glhf_negotiations_total"} {
+		if !strings.Contains(w.Body.String(), name) {
+			t.Errorf("metrics handler is missing %s", name)
+		}
+	}
+}
+
+func TestCollectorUnknownRoute(t *testing.T) {
+	c := New()
+	handler := glhf.Get(func(r *glhf.Request[glhf.EmptyBody], w *glhf.Response[todo]) {
+		w.SetBody(&todo{Title: "glhf"})
+	}, glhf.WithObserver(c))
+
+	for _, path := range []string{"/todo/1", "/todo/2"} {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if n := testutil.ToFloat64(c.requests.WithLabelValues(glhf.UnknownRoute, http.MethodGet, "200")); n != 2 {
+		t.Errorf("requests = %v; expected 2 under the %s route", n, glhf.UnknownRoute)
+	}
+	if n := testutil.CollectAndCount(c.requests); n != 1 {
+		t.Errorf("found %d request series; expected 1", n)
+	}
+}
+
+func TestCollectorUnknownMethod(t *testing.T) {
+	c := New()
+	handler := glhf.Get(func(r *glhf.Request[glhf.EmptyBody], w *glhf.Response[todo]) {
+		w.SetBody(&todo{Title: "glhf"})
+	}, glhf.WithObserver(c), glhf.WithRoute("/todo"))
+
+	for _, method := range []string{"BREW", "WHEN", http.MethodDelete} {
+		handler(httptest.NewRecorder(), httptest.NewRequest(method, "/todo", nil))
+	}
+
+	if n := testutil.ToFloat64(c.requests.WithLabelValues("/todo", otherMethod, "405")); n != 2 {
+		t.Errorf("requests = %v; expected 2 under the %s method", n, otherMethod)
+	}
+	if n := testutil.ToFloat64(c.requests.WithLabelValues("/todo", http.MethodDelete, "405")); n != 1 {
+		t.Errorf("requests = %v; expected 1 under the %s method", n, http.MethodDelete)
+	}
+	if n := testutil.CollectAndCount(c.requests); n != 2 {
+		t.Errorf("found %d request series; expected 2", n)
+	}
+}
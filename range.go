@@ -0,0 +1,73 @@
+package glhf
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+)
+
+const (
+	// AcceptRanges header constant.
+	AcceptRanges = "Accept-Ranges"
+	// Range header constant.
+	Range = "Range"
+
+	// statusWritten is returned by render when the handler's response was already written, i.e a streamed body.
+	statusWritten = -1
+)
+
+// readSeeker returns the response body as an io.ReadSeeker if O, or a pointer to O, implements it.
+func readSeeker[O Body](body *O) (io.ReadSeeker, bool) {
+	if rs, ok := any(body).(io.ReadSeeker); ok {
+		return rs, true
+	}
+	if rs, ok := any(*body).(io.ReadSeeker); ok {
+		return rs, true
+	}
+	return nil, false
+}
+
+// bufferContent reads a seekable body into memory so it can be stored, i.e by the response cache.
+// Range requests are answered from the buffered body when it is served.
+func bufferContent(w http.ResponseWriter, content io.ReadSeeker) ([]byte, error) {
+	if closer, ok := content.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	b, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(w.Header().Get(ContentType)) == 0 {
+		w.Header().Set(ContentType, ContentBinary)
+	}
+	w.Header().Set(AcceptRanges, "bytes")
+	return b, nil
+}
+
+// serveContent writes a seekable body using http.ServeContent, which answers Range and If-Range requests with
+// 206 Partial Content, multipart/byteranges for multiple ranges and 416 for unsatisfiable ranges.
+func serveContent(w http.ResponseWriter, r *http.Request, content io.ReadSeeker) {
+	if closer, ok := content.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// prevent http.ServeContent from sniffing the content-type
+	if len(w.Header().Get(ContentType)) == 0 {
+		w.Header().Set(ContentType, ContentBinary)
+	}
+
+	modtime := lastModifiedHeader(w.Header())
+	if f, ok := content.(interface{ Stat() (fs.FileInfo, error) }); ok && modtime.IsZero() {
+		if fi, err := f.Stat(); err == nil {
+			modtime = fi.ModTime()
+		}
+	}
+	http.ServeContent(w, r, "", modtime, content)
+}
+
+// serveBuffered writes a buffered seekable body, answering Range requests.
+func serveBuffered(w http.ResponseWriter, r *http.Request, b []byte) {
+	serveContent(w, r, bytes.NewReader(b))
+}
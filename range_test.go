@@ -0,0 +1,99 @@
+package glhf
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRangeRequests(t *testing.T) {
+	content := []byte("0123456789")
+
+	testCases := []struct {
+		name     string
+		rangeHdr string
+		expected int
+		body     string
+	}{
+		{"full", "", http.StatusOK, "0123456789"},
+		{"single range", "bytes=2-4", http.StatusPartialContent, "234"},
+		{"suffix range", "bytes=-3", http.StatusPartialContent, "789"},
+		{"unsatisfiable", "bytes=20-30", http.StatusRequestedRangeNotSatisfiable, ""},
+	}
+
+	handlers := map[string]http.HandlerFunc{
+		"streamed": Get(func(r *Request[EmptyBody], w *Response[bytes.Reader]) {
+			w.SetBody(bytes.NewReader(content))
+		}),
+		"cached": Get(func(r *Request[EmptyBody], w *Response[bytes.Reader]) {
+			w.SetBody(bytes.NewReader(content))
+		}, WithCachePolicy(CachePolicy{MaxAge: time.Minute}), WithResponseCache(NewMemoryCacheStore(1))),
+	}
+
+	for name, handler := range handlers {
+		for _, testCase := range testCases {
+			t.Run(name+" "+testCase.name, func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				if len(testCase.rangeHdr) > 0 {
+					r.Header.Set(Range, testCase.rangeHdr)
+				}
+				w := httptest.NewRecorder()
+				handler(w, r)
+
+				if w.Code != testCase.expected {
+					t.Fatalf("status = %d; expected %d", w.Code, testCase.expected)
+				}
+				if len(testCase.body) > 0 && w.Body.String() != testCase.body {
+					t.Errorf("body = %q; expected %q", w.Body.String(), testCase.body)
+				}
+				if actual := w.Header().Get(ContentType); w.Code == http.StatusOK && actual != ContentBinary {
+					t.Errorf("Content-Type = %q; expected %q", actual, ContentBinary)
+				}
+			})
+		}
+	}
+}
+
+func TestRangeResponseHooks(t *testing.T) {
+	content := []byte("0123456789")
+	digest := contentDigest(content, []DigestAlgorithm{DigestSHA256})
+
+	handlers := map[string]http.HandlerFunc{
+		"buffered": Get(func(r *Request[EmptyBody], w *Response[bytes.Reader]) {
+			w.SetBody(bytes.NewReader(content))
+		}, WithResponseHook(ContentDigestHook())),
+		"cached": Get(func(r *Request[EmptyBody], w *Response[bytes.Reader]) {
+			w.SetBody(bytes.NewReader(content))
+		}, WithCachePolicy(CachePolicy{MaxAge: time.Minute}), WithResponseCache(NewMemoryCacheStore(1)),
+			WithResponseHook(ContentDigestHook())),
+	}
+
+	testCases := []struct {
+		name     string
+		rangeHdr string
+		expected string
+	}{
+		{name: "full", expected: digest},
+		{name: "cache hit", expected: digest},
+		{name: "range", rangeHdr: "bytes=2-4"},
+	}
+
+	for name, handler := range handlers {
+		for _, testCase := range testCases {
+			t.Run(name+" "+testCase.name, func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				if len(testCase.rangeHdr) > 0 {
+					r.Header.Set(Range, testCase.rangeHdr)
+				}
+				w := httptest.NewRecorder()
+				handler(w, r)
+
+				if actual := w.Header().Get(ContentDigest); actual != testCase.expected {
+					t.Errorf("%s = %q; expected %q", ContentDigest, actual, testCase.expected)
+				}
+			})
+		}
+	}
+}
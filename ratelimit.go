@@ -0,0 +1,275 @@
+package glhf
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// RetryAfter header constant.
+	RetryAfter = "Retry-After"
+	// RateLimitLimit header constant, IETF RateLimit header fields draft.
+	RateLimitLimit = "RateLimit-Limit"
+	// RateLimitRemaining header constant, IETF RateLimit header fields draft.
+	RateLimitRemaining = "RateLimit-Remaining"
+	// RateLimitReset header constant, IETF RateLimit header fields draft.
+	RateLimitReset = "RateLimit-Reset"
+	// RateLimitPolicy header constant, IETF RateLimit header fields draft.
+	RateLimitPolicy = "RateLimit-Policy"
+)
+
+// RateLimitKeyFunc returns the key a request is rate limited by. Requests with an empty key are not limited.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// KeyByIP rate limits requests by the client IP address of the connection.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByHeader rate limits requests by the value of header, i.e an API key.
+func KeyByHeader(header string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// KeyByPrincipal rate limits requests by the subject of their principal, unauthenticated requests by client IP.
+func KeyByPrincipal(r *http.Request) string {
+	if p := ContextPrincipal(r.Context()); p != nil {
+		return "principal:" + p.Subject()
+	}
+	return KeyByIP(r)
+}
+
+// RateLimit configures a token bucket rate limit.
+type RateLimit struct {
+	// Limit is the number of requests allowed per Period.
+	Limit int
+	// Period is the time in which the bucket is refilled with Limit tokens.
+	Period time.Duration
+	// Burst is the size of the bucket, the number of requests that can be made at once. Defaults to Limit.
+	Burst int
+	// Key returns the key a request is limited by. Defaults to KeyByIP.
+	Key RateLimitKeyFunc
+	// Store holds the buckets. Defaults to a MemoryRateLimitStore per handler, handlers sharing a store share
+	// the buckets of a key.
+	Store RateLimitStore
+}
+
+// RateLimitStatus is the state of a bucket after a token was taken.
+type RateLimitStatus struct {
+	// Allowed is false if the bucket was empty.
+	Allowed bool
+	// Remaining is the number of tokens left in the bucket.
+	Remaining int
+	// Reset is the time until the bucket is full.
+	Reset time.Duration
+	// RetryAfter is the time until a token is available, zero if Allowed is true.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore stores token buckets. Implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key, refilled with limit tokens per period up to burst tokens.
+	Take(key string, limit int, period time.Duration, burst int) (RateLimitStatus, error)
+}
+
+// RateLimitRefunder is implemented by a RateLimitStore that can return a taken token. When a request exceeds one of
+// several limits, the tokens it took from the other limits' stores are refunded if they implement it.
+type RateLimitRefunder interface {
+	// Refund returns a token taken with Take to the bucket of key.
+	Refund(key string, limit int, period time.Duration, burst int) error
+}
+
+// MemoryRateLimitStore is an in-memory RateLimitStore and RateLimitRefunder.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	inserts int
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Take takes a token from the bucket of key.
+func (s *MemoryRateLimitStore) Take(key string, limit int, period time.Duration, burst int) (RateLimitStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	rate := float64(limit) / period.Seconds()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		// drop full buckets, they are equivalent to a new bucket, every sweepInterval new buckets so taking a
+		// token stays amortized constant time
+		if s.inserts++; s.inserts%sweepInterval == 0 {
+			for k, b := range s.buckets {
+				if !now.Before(b.full) {
+					delete(s.buckets, k)
+				}
+			}
+		}
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	status := RateLimitStatus{Allowed: b.tokens >= 1}
+	if status.Allowed {
+		b.tokens--
+	} else {
+		status.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	status.Remaining = int(b.tokens)
+	status.Reset = time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second))
+	b.full = now.Add(status.Reset)
+	return status, nil
+}
+
+// Refund returns a token to the bucket of key.
+func (s *MemoryRateLimitStore) Refund(key string, limit int, period time.Duration, burst int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		return nil
+	}
+	now := s.now()
+	rate := float64(limit) / period.Seconds()
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate+1)
+	b.last = now
+	b.full = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+	return nil
+}
+
+// rateLimiter enforces a RateLimit.
+type rateLimiter struct {
+	limit RateLimit
+}
+
+// newRateLimiter returns a limiter enforcing limit, it panics if Limit or Period are not positive.
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.Limit <= 0 || limit.Period <= 0 {
+		panic("glhf: rate limits require a positive limit and period")
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Limit
+	}
+	if limit.Key == nil {
+		limit.Key = KeyByIP
+	}
+	if limit.Store == nil {
+		limit.Store = NewMemoryRateLimitStore()
+	}
+	return &rateLimiter{limit: limit}
+}
+
+// allow takes a token for the request and sets the RateLimit headers, it returns the key of the bucket a token was
+// taken from. Requests exceeding the limit fail with 429. If the store fails the request is allowed.
+func (l *rateLimiter) allow(w http.ResponseWriter, r *http.Request) (string, *errorResponse) {
+	key := l.limit.Key(r)
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	status, err := l.limit.Store.Take(key, l.limit.Limit, l.limit.Period, l.limit.Burst)
+	if err != nil {
+		return "", nil
+	}
+
+	w.Header().Set(RateLimitLimit, strconv.Itoa(l.limit.Burst))
+	w.Header().Set(RateLimitRemaining, strconv.Itoa(status.Remaining))
+	w.Header().Set(RateLimitReset, strconv.Itoa(ceilSeconds(status.Reset)))
+	w.Header().Set(RateLimitPolicy, strconv.Itoa(l.limit.Limit)+";w="+strconv.Itoa(ceilSeconds(l.limit.Period)))
+	if status.Allowed {
+		return key, nil
+	}
+
+	w.Header().Set(RetryAfter, strconv.Itoa(ceilSeconds(status.RetryAfter)))
+	return "", &errorResponse{
+		Code:    http.StatusTooManyRequests,
+		Message: "rate limit exceeded",
+	}
+}
+
+// refund returns the token taken from the bucket of key, if the store supports refunds.
+func (l *rateLimiter) refund(key string) {
+	if refunder, ok := l.limit.Store.(RateLimitRefunder); ok {
+		_ = refunder.Refund(key, l.limit.Limit, l.limit.Period, l.limit.Burst)
+	}
+}
+
+// limitRequest takes a token from every limiter, the request fails with the first limit it exceeds. Tokens taken
+// from the limiters before it are refunded, a rejected request does not use up the other limits.
+func limitRequest(w http.ResponseWriter, r *http.Request, limiters []*rateLimiter) *errorResponse {
+	keys := make([]string, 0, len(limiters))
+	for _, limiter := range limiters {
+		key, errResp := limiter.allow(w, r)
+		if errResp != nil {
+			for j, taken := range keys {
+				if len(taken) > 0 {
+					limiters[j].refund(taken)
+				}
+			}
+			return errResp
+		}
+		keys = append(keys, key)
+	}
+	return nil
+}
+
+// ceilSeconds rounds d up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// inFlightLimiter limits the number of requests handled concurrently.
+type inFlightLimiter struct {
+	slots chan struct{}
+}
+
+func newInFlightLimiter(n int) *inFlightLimiter {
+	return &inFlightLimiter{slots: make(chan struct{}, n)}
+}
+
+// acquire reserves a slot for the request, it fails with 503 if every slot is taken. release must be called
+// once the request completes.
+func (l *inFlightLimiter) acquire() *errorResponse {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+		return &errorResponse{
+			Code:    http.StatusServiceUnavailable,
+			Message: "too many requests in flight",
+		}
+	}
+}
+
+func (l *inFlightLimiter) release() {
+	<-l.slots
+}
@@ -0,0 +1,179 @@
+package glhf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewMemoryRateLimitStore()
+	s.now = func() time.Time { return now }
+
+	tests := []struct {
+		advance    time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{allowed: true, remaining: 1},
+		{allowed: true, remaining: 0},
+		{allowed: false, remaining: 0, retryAfter: 30 * time.Second},
+		{advance: 15 * time.Second, allowed: false, remaining: 0, retryAfter: 15 * time.Second},
+		{advance: 15 * time.Second, allowed: true, remaining: 0},
+		{advance: 2 * time.Minute, allowed: true, remaining: 1},
+	}
+
+	// 2 requests per minute
+	for i, tt := range tests {
+		now = now.Add(tt.advance)
+		status, err := s.Take("key", 2, time.Minute, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Allowed != tt.allowed || status.Remaining != tt.remaining || status.RetryAfter != tt.retryAfter {
+			t.Errorf("%d: status = %+v; expected allowed %t, remaining %d, retry after %s", i, status, tt.allowed, tt.remaining, tt.retryAfter)
+		}
+	}
+
+	if err := s.Refund("key", 2, time.Minute, 2); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := s.Take("key", 2, time.Minute, 2); !status.Allowed || status.Remaining != 1 {
+		t.Errorf("status after refund = %+v; expected allowed, remaining 1", status)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {},
+		WithRateLimit(RateLimit{Limit: 1, Period: time.Minute, Key: KeyByHeader(APIKeyHeader)}))
+
+	request := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	if w := request("a"); w.Code != http.StatusOK || w.Header().Get(RateLimitRemaining) != "0" || w.Header().Get(RateLimitPolicy) != "1;w=60" {
+		t.Errorf("first request = %d %v", w.Code, w.Header())
+	}
+	w := request("a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get(RetryAfter) != "60" || w.Header().Get(RateLimitReset) != "60" {
+		t.Errorf("limited request = %d %v", w.Code, w.Header())
+	}
+	if w := request("b"); w.Code != http.StatusOK {
+		t.Errorf("request with another key = %d; expected %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimitFailedAuthentication(t *testing.T) {
+	handler := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {},
+		WithAuth(NewBasicAuthenticator("glhf", BasicUsers(map[string]string{"alice": "secret"}))),
+		WithRateLimit(RateLimit{Limit: 2, Period: time.Minute}))
+
+	tests := []struct {
+		password string
+		expected int
+	}{
+		{password: "guess", expected: http.StatusUnauthorized},
+		{password: "guess", expected: http.StatusUnauthorized},
+		{password: "guess", expected: http.StatusTooManyRequests},
+		{password: "secret", expected: http.StatusTooManyRequests},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.SetBasicAuth("alice", tt.password)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != tt.expected {
+			t.Errorf("%d: status = %d; expected %d", i, w.Code, tt.expected)
+		}
+	}
+}
+
+func TestRateLimitStacked(t *testing.T) {
+	global := func(r *http.Request) string { return "global" }
+	handler := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {},
+		WithRateLimit(RateLimit{Limit: 2, Period: time.Minute, Key: global}),
+		WithRateLimit(RateLimit{Limit: 1, Period: time.Minute, Key: KeyByHeader(APIKeyHeader)}))
+
+	tests := []struct {
+		key        string
+		statusCode int
+	}{
+		{key: "a", statusCode: http.StatusOK},
+		{key: "a", statusCode: http.StatusTooManyRequests},
+		{key: "a", statusCode: http.StatusTooManyRequests},
+		{key: "a", statusCode: http.StatusTooManyRequests},
+		{key: "b", statusCode: http.StatusOK},
+		{key: "c", statusCode: http.StatusTooManyRequests},
+	}
+
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(APIKeyHeader, tt.key)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != tt.statusCode {
+			t.Errorf("%d: status of %s = %d; expected %d", i, tt.key, w.Code, tt.statusCode)
+		}
+	}
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit RateLimit
+	}{
+		{name: "no limit", limit: RateLimit{Period: time.Minute}},
+		{name: "no period", limit: RateLimit{Limit: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected the handler to panic")
+				}
+			}()
+			Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {}, WithRateLimit(tt.limit))
+		})
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	started := make(chan struct{})
+	block := make(chan struct{})
+	handler := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		if r.URL().Query().Get("block") == "true" {
+			close(started)
+			<-block
+		}
+	}, WithMaxInFlight(1))
+
+	done := make(chan struct{})
+	go func() {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?block=true", nil))
+		close(done)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d; expected %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	// the slot is released once the first request completes
+	close(block)
+	<-done
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d; expected %d", w.Code, http.StatusOK)
+	}
+}
@@ -9,8 +9,9 @@ import (
 
 // A Request represents an HTTP request received by a server
 type Request[T Body] struct {
-	r    *http.Request
-	body *T
+	r       *http.Request
+	body    *T
+	hasBody bool
 }
 
 // HTTPRequest returns the raw HTTP Request. If the request contains a body,
@@ -25,6 +26,28 @@ func (req *Request[T]) Body() *T {
 	return req.body
 }
 
+// HasBody reports whether the client sent a request body. Handlers that ignore the request body, i.e Get,
+// report false.
+func (req *Request[T]) HasBody() bool {
+	return req.hasBody
+}
+
+// ID returns the request ID, or an empty string if request IDs are not enabled with WithRequestID.
+func (req *Request[T]) ID() string {
+	return ContextRequestID(req.r.Context())
+}
+
+// Principal returns the authenticated principal of the request, or nil if authentication is not enabled with WithAuth.
+func (req *Request[T]) Principal() Principal {
+	return ContextPrincipal(req.r.Context())
+}
+
+// CSRFToken returns the CSRF token to embed in forms or send in the X-CSRF-Token header, or an empty string if
+// CSRF protection is not enabled with WithCSRF.
+func (req *Request[T]) CSRFToken() string {
+	return ContextCSRFToken(req.r.Context())
+}
+
 // Header wraps http.Request.Header
 func (req *Request[T]) Header() http.Header {
 	return req.r.Header
@@ -0,0 +1,84 @@
+package glhf
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	// RequestID header constant.
+	RequestID = "X-Request-ID"
+	// Traceparent header constant, W3C Trace Context.
+	Traceparent = "traceparent"
+
+	// maxRequestIDLength is the longest request ID accepted from a client.
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// ContextRequestID returns the request ID stored in ctx, or an empty string if ctx has none.
+func ContextRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID stores the request's ID in its context and echoes it in the X-Request-ID response header.
+// An ID already stored in the context is kept, otherwise it is read from the X-Request-ID header, the trace-id
+// of the traceparent header, or generated.
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := ContextRequestID(r.Context())
+	if len(id) == 0 {
+		id = readRequestID(r)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+	}
+	w.Header().Set(RequestID, id)
+	return r
+}
+
+func readRequestID(r *http.Request) string {
+	if id := r.Header.Get(RequestID); validRequestID(id) {
+		return id
+	}
+	if traceID, ok := parseTraceparent(r.Header.Get(Traceparent)); ok {
+		return traceID
+	}
+	return newRequestID()
+}
+
+// validRequestID reports whether a client supplied ID is safe to log and echo, printable ASCII without spaces.
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTraceparent returns the trace-id of a traceparent header, version-traceid-parentid-flags.
+func parseTraceparent(traceparent string) (string, bool) {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", false
+	}
+	traceID := parts[1]
+	if _, err := hex.DecodeString(traceID); err != nil || traceID != strings.ToLower(traceID) ||
+		traceID == strings.Repeat("0", 32) {
+		return "", false
+	}
+	return traceID, true
+}
+
+// newRequestID returns a random ID formatted like a W3C trace-id.
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
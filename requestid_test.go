@@ -0,0 +1,103 @@
+package glhf
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   map[string]string
+		expected string
+	}{
+		{
+			name:     "request id header",
+			header:   map[string]string{RequestID: "req-1"},
+			expected: "req-1",
+		},
+		{
+			name:     "traceparent",
+			header:   map[string]string{Traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			expected: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name: "request id header preferred",
+			header: map[string]string{
+				RequestID:   "req-1",
+				Traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+			expected: "req-1",
+		},
+		{
+			name:   "invalid request id generated",
+			header: map[string]string{RequestID: "req 1\n"},
+		},
+		{
+			name:   "invalid traceparent generated",
+			header: map[string]string{Traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		},
+		{
+			name: "generated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var id string
+			handler := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+				id = r.ID()
+			}, WithRequestID(true))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if len(tt.expected) > 0 && id != tt.expected {
+				t.Errorf("Request.ID() = %s; expected %s", id, tt.expected)
+			}
+			if len(tt.expected) == 0 && len(id) != 32 {
+				t.Errorf("Request.ID() = %s; expected a generated id", id)
+			}
+			if w.Header().Get(RequestID) != id {
+				t.Errorf("%s = %s; expected %s", RequestID, w.Header().Get(RequestID), id)
+			}
+		})
+	}
+}
+
+func TestRequestIDCorrelation(t *testing.T) {
+	type todo struct {
+		Title string `json:"title"`
+	}
+
+	var buf bytes.Buffer
+	handler := Post(func(r *Request[todo], w *Response[todo]) {
+		w.SetBody(r.Body())
+	}, WithRequestID(true), WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{`))
+	r.Header.Set(ContentType, ContentJSON)
+	r.Header.Set(RequestID, "req-1")
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	p := problem{}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.RequestID != "req-1" {
+		t.Errorf("error body request_id = %s; expected req-1", p.RequestID)
+	}
+	if !strings.Contains(buf.String(), `"request_id":"req-1"`) {
+		t.Errorf("log record missing request id: %s", buf.String())
+	}
+}
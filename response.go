@@ -2,14 +2,17 @@ package glhf
 
 import (
 	"net/http"
+	"time"
 )
 
 // Response represents the response from an HTTP request.
 type Response[T any] struct {
 	w          http.ResponseWriter
+	r          *http.Request
 	statusCode int
 	body       *T
 	marshal    MarshalFunc[T]
+	err        *errorResponse
 }
 
 // SetHeader sets the header entries associated with key to the single element value.
@@ -42,3 +45,31 @@ func (res *Response[T]) SetBody(t *T) {
 func (res *Response[T]) SetMarshalFunc(fn MarshalFunc[T]) {
 	res.marshal = fn
 }
+
+// SetETag sets the ETag header of the response to the user supplied version, i.e "v1" or W/"v1".
+// Unquoted versions are quoted. A user supplied ETag takes precedence over a computed one.
+func (res *Response[T]) SetETag(etag string) {
+	res.w.Header().Set(ETag, quoteETag(etag))
+}
+
+// SetLastModified sets the Last-Modified header of the response.
+func (res *Response[T]) SetLastModified(t time.Time) {
+	res.w.Header().Set(LastModified, t.UTC().Format(http.TimeFormat))
+}
+
+// CheckVersion enforces optimistic concurrency against the current version of the resource. It evaluates the request's
+// If-Match header against current and returns true if the resource may be modified. Otherwise the response is answered
+// with 412 Precondition Failed and the handler must return without modifying the resource. If-Unmodified-Since is not
+// evaluated, it is only honoured with WithValidators.
+// After a successful update, the new version should be set with SetETag.
+func (res *Response[T]) CheckVersion(current string) bool {
+	im := res.r.Header.Get(IfMatch)
+	if len(im) == 0 || matchETag(im, quoteETag(current), len(current) > 0, true) {
+		return true
+	}
+	res.err = &errorResponse{
+		Code:    http.StatusPreconditionFailed,
+		Message: "precondition failed, resource version does not match If-Match",
+	}
+	return false
+}
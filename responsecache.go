@@ -0,0 +1,383 @@
+package glhf
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Age header constant.
+const Age = "Age"
+
+// CachedResponse is an encoded response stored by the response cache.
+type CachedResponse struct {
+	// StatusCode is the http status code of the response.
+	StatusCode int
+	// Header is the response header, excluding content-coding which is applied when the response is served.
+	Header http.Header
+	// Body is the encoded, uncompressed, response body.
+	Body []byte
+	// StoredAt is the time the response was stored.
+	StoredAt time.Time
+	// Expires is the time the response becomes stale.
+	Expires time.Time
+	// StaleUntil is the time until which a stale response may be served while it is revalidated.
+	StaleUntil time.Time
+}
+
+// CacheStore stores cached responses. Implementations must be safe for concurrent use.
+type CacheStore interface {
+	// Get returns the response stored for key.
+	Get(key string) (*CachedResponse, bool)
+	// Set stores the response for key, the response may be evicted after ttl.
+	Set(key string, res *CachedResponse, ttl time.Duration)
+	// Delete removes the response stored for key.
+	Delete(key string)
+}
+
+// MemoryCacheStore is an in-memory CacheStore evicting the least recently used responses.
+type MemoryCacheStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	entries    map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key     string
+	res     *CachedResponse
+	expires time.Time
+}
+
+// NewMemoryCacheStore returns a MemoryCacheStore holding at most maxEntries responses.
+// A maxEntries of zero or less does not limit the number of responses.
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the response stored for key if it has not expired.
+func (s *MemoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.expires) {
+		s.ll.Remove(e)
+		delete(s.entries, key)
+		return nil, false
+	}
+	s.ll.MoveToFront(e)
+	return entry.res, true
+}
+
+// Set stores the response for key, evicting the least recently used response if the store is full.
+func (s *MemoryCacheStore) Set(key string, res *CachedResponse, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := &memoryCacheEntry{key: key, res: res, expires: time.Now().Add(ttl)}
+	if e, ok := s.entries[key]; ok {
+		e.Value = entry
+		s.ll.MoveToFront(e)
+		return
+	}
+	s.entries[key] = s.ll.PushFront(entry)
+
+	if s.maxEntries > 0 && s.ll.Len() > s.maxEntries {
+		oldest := s.ll.Back()
+		s.ll.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+}
+
+// Delete removes the response stored for key.
+func (s *MemoryCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		s.ll.Remove(e)
+		delete(s.entries, key)
+	}
+}
+
+// renderFunc calls the handler and encodes its response. Headers are written to w.
+type renderFunc func(w http.ResponseWriter, r *http.Request) (int, []byte, *errorResponse)
+
+// responseCache serves GET responses from a CacheStore, coalescing concurrent misses for the same key.
+type responseCache struct {
+	store  CacheStore
+	flight flightGroup
+}
+
+func newResponseCache(store CacheStore) *responseCache {
+	return &responseCache{store: store}
+}
+
+// serve returns the cached response for r, calling render on a miss. Headers of the response are copied to w.
+// Stale responses are revalidated in the background with refresh, which must not report to the request's
+// logger or observers as the request completes before it returns.
+func (c *responseCache) serve(w http.ResponseWriter, r *http.Request, render, refresh renderFunc) (int, []byte, *errorResponse) {
+	base := baseCacheKey(r)
+	now := time.Now()
+
+	key, res, ok := c.lookup(base, r)
+	if ok {
+		if now.Before(res.Expires) {
+			return writeCached(w, res, now)
+		}
+		if now.Before(res.StaleUntil) {
+			// serve stale and revalidate in the background
+			go c.fill(key, base, r.Clone(context.WithoutCancel(r.Context())), refresh)
+			return writeCached(w, res, now)
+		}
+	}
+
+	res, errResp := c.fill(key, base, r, render)
+	if errResp != nil {
+		return 0, nil, errResp
+	}
+	return writeCached(w, res, now)
+}
+
+// lookup returns the variant key of the request and the cached response matching it. The base key is returned
+// if the Vary headers of the resource are not known.
+func (c *responseCache) lookup(base string, r *http.Request) (string, *CachedResponse, bool) {
+	index, ok := c.store.Get(base)
+	if !ok {
+		return base, nil, false
+	}
+	key := variantCacheKey(base, varyHeaders(index.Header), r)
+	res, ok := c.store.Get(key)
+	return key, res, ok
+}
+
+// fill renders the response and stores it if it is cacheable. Concurrent misses for the same key and credentials
+// are coalesced into a single render, its response is only shared if it was stored and the waiting request
+// matches its variant. Otherwise the waiting request renders its own response.
+func (c *responseCache) fill(key string, base string, r *http.Request, render renderFunc) (*CachedResponse, *errorResponse) {
+	res, variant, errResp, leader := c.flight.do(flightKey(key, r), func() (*CachedResponse, string, *errorResponse) {
+		return c.render(base, r, render)
+	})
+	if leader {
+		return res, errResp
+	}
+	if res != nil && variantCacheKey(base, varyHeaders(res.Header), r) == variant {
+		return res, nil
+	}
+
+	res, _, errResp = c.render(base, r, render)
+	return res, errResp
+}
+
+// render calls render and stores the response if it is cacheable. It returns the variant key the response is
+// stored under, or an empty string if it was not stored.
+func (c *responseCache) render(base string, r *http.Request, render renderFunc) (*CachedResponse, string, *errorResponse) {
+	rec := &headerRecorder{header: make(http.Header)}
+	statusCode, b, errResp := render(rec, r)
+	if errResp != nil {
+		return nil, "", errResp
+	}
+
+	now := time.Now()
+	res := &CachedResponse{
+		StatusCode: statusCode,
+		Header:     rec.header,
+		Body:       b,
+		StoredAt:   now,
+	}
+
+	maxAge, stale, ok := cacheLifetime(rec.header.Get(CacheControl), len(r.Header.Get(Authorization)) > 0 || ContextPrincipal(r.Context()) != nil)
+	// cookies belong to the client the response was rendered for, they must not be replayed to others
+	if !ok || statusCode != http.StatusOK || len(rec.header.Values("Set-Cookie")) > 0 {
+		return res, "", nil
+	}
+	res.Expires = now.Add(maxAge)
+	res.StaleUntil = res.Expires.Add(stale)
+
+	vary := varyHeaders(rec.header)
+	for _, name := range vary {
+		// the response varies on more than request headers
+		if name == "*" {
+			return res, "", nil
+		}
+	}
+	key := variantCacheKey(base, vary, r)
+	ttl := maxAge + stale
+	c.store.Set(base, &CachedResponse{Header: http.Header{Vary: vary}, StoredAt: now}, ttl)
+	c.store.Set(key, res, ttl)
+	return res, key, nil
+}
+
+// varyHeaders returns the canonical names of the Vary headers of a response that select its variant.
+func varyHeaders(h http.Header) []string {
+	var vary []string
+	for _, v := range h.Values(Vary) {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			// Accept is part of the base key and content-coding is applied when the response is served
+			if len(name) > 0 && name != Accept && name != AcceptEncoding {
+				vary = append(vary, name)
+			}
+		}
+	}
+	return vary
+}
+
+// flightKey returns the key concurrent misses are coalesced on, requests with different credentials never share
+// a render.
+func flightKey(key string, r *http.Request) string {
+	var subject string
+	if p := ContextPrincipal(r.Context()); p != nil {
+		subject = p.Subject()
+	}
+	return key + "\n" + r.Header.Get(Authorization) + "\n" + subject
+}
+
+// writeCached copies the cached response headers to w.
+func writeCached(w http.ResponseWriter, res *CachedResponse, now time.Time) (int, []byte, *errorResponse) {
+	for k, v := range res.Header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	if !res.Expires.IsZero() {
+		w.Header().Set(Age, strconv.FormatInt(int64(now.Sub(res.StoredAt)/time.Second), 10))
+	}
+	return res.StatusCode, res.Body, nil
+}
+
+// baseCacheKey returns the cache key of a request before Vary headers are applied. It includes the
+// content-type negotiated from the Accept header.
+func baseCacheKey(r *http.Request) string {
+	var contentType string
+	for _, mediaType := range acceptedMediaTypes(r.Header.Get(Accept)) {
+		if _, _, ok := lookupCodec(mediaType); ok {
+			contentType = mediaType
+			break
+		}
+	}
+	return http.MethodGet + " " + r.Host + r.URL.RequestURI() + " " + contentType
+}
+
+// variantCacheKey returns the cache key of a request including the values of the Vary headers.
+func variantCacheKey(base string, vary []string, r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(base)
+	for _, name := range vary {
+		sb.WriteString("\n" + name + ": " + strings.Join(r.Header.Values(name), ", "))
+	}
+	return sb.String()
+}
+
+// cacheLifetime returns the freshness lifetime and stale-while-revalidate window of a Cache-Control header.
+// ok is false if the response must not be stored.
+func cacheLifetime(cacheControl string, authorized bool) (maxAge time.Duration, stale time.Duration, ok bool) {
+	var (
+		public       bool
+		hasMaxAge    bool
+		hasSharedAge bool
+	)
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		n, _ := strconv.Atoi(strings.Trim(value, `"`))
+		d := time.Duration(n) * time.Second
+
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache", "private":
+			return 0, 0, false
+		case "public":
+			public = true
+		case "max-age":
+			if !hasSharedAge {
+				maxAge, hasMaxAge = d, true
+			}
+		case "s-maxage":
+			maxAge, hasSharedAge = d, true
+		case "stale-while-revalidate":
+			stale = d
+		}
+	}
+
+	// responses to authorized requests are only shared if explicitly allowed, RFC 9111 section 3.5
+	if authorized && !public && !hasSharedAge {
+		return 0, 0, false
+	}
+	if (!hasMaxAge && !hasSharedAge) || maxAge <= 0 {
+		return 0, 0, false
+	}
+	return maxAge, stale, true
+}
+
+// flightGroup coalesces concurrent calls with the same key into a single call.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg      sync.WaitGroup
+	res     *CachedResponse
+	variant string
+}
+
+// do calls fn once for concurrent callers with the same key. fn returns the response and the variant key it was
+// stored under, or an empty variant key if it was not stored. The caller that called fn receives its results and
+// leader true, the callers that waited receive the stored response and its variant key, or nil if it was not stored.
+// Errors are never shared.
+func (g *flightGroup) do(key string, fn func() (*CachedResponse, string, *errorResponse)) (res *CachedResponse, variant string, errResp *errorResponse, leader bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		if len(call.variant) == 0 {
+			return nil, "", nil, false
+		}
+		return call.res, call.variant, nil, false
+	}
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	res, variant, errResp = fn()
+	if errResp == nil {
+		call.res, call.variant = res, variant
+	}
+	return res, variant, errResp, true
+}
+
+// headerRecorder is a http.ResponseWriter that only records headers.
+type headerRecorder struct {
+	header http.Header
+}
+
+func (h *headerRecorder) Header() http.Header {
+	return h.header
+}
+
+func (h *headerRecorder) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (h *headerRecorder) WriteHeader(int) {}
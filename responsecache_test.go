@@ -0,0 +1,183 @@
+package glhf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	type message struct {
+		Count int32 `json:"count"`
+	}
+
+	var calls int32
+	handler := Get(func(r *Request[EmptyBody], w *Response[message]) {
+		time.Sleep(10 * time.Millisecond)
+		w.SetBody(&message{Count: atomic.AddInt32(&calls, 1)})
+	}, WithCachePolicy(CachePolicy{MaxAge: time.Minute}), WithResponseCache(NewMemoryCacheStore(10)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodGet, "/todo", nil))
+			if w.Code != http.StatusOK || w.Body.String() != `{"count":1}` {
+				t.Errorf("status = %d, body = %s; expected 200 with the first response", w.Code, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("handler called %d times; expected 1", calls)
+	}
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/todo", nil))
+	if calls != 1 {
+		t.Errorf("handler called %d times; expected a cache hit", calls)
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/todo?page=2", nil))
+	if calls != 2 {
+		t.Errorf("handler called %d times; expected a miss for a different url", calls)
+	}
+}
+
+func TestResponseCacheCookies(t *testing.T) {
+	var calls int
+	handler := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		calls++
+		w.SetHeader("Set-Cookie", "session="+r.URL().Query().Get("user"))
+	}, WithCachePolicy(CachePolicy{MaxAge: time.Minute}), WithResponseCache(NewMemoryCacheStore(10)))
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/todo?user=user1", nil))
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/todo?user=user1", nil))
+	if calls != 2 || w.Header().Get("Set-Cookie") != "session=user1" {
+		t.Errorf("handler called %d times, Set-Cookie %q; expected responses setting cookies not to be cached", calls, w.Header().Get("Set-Cookie"))
+	}
+}
+
+func TestCacheLifetime(t *testing.T) {
+	testCases := []struct {
+		cacheControl string
+		authorized   bool
+		maxAge       time.Duration
+		stale        time.Duration
+		ok           bool
+	}{
+		{"", false, 0, 0, false},
+		{"max-age=60", false, time.Minute, 0, true},
+		{"max-age=60, s-maxage=10, stale-while-revalidate=30", false, 10 * time.Second, 30 * time.Second, true},
+		{"no-store, max-age=60", false, 0, 0, false},
+		{"max-age=60", true, 0, 0, false},
+		{"public, max-age=60", true, time.Minute, 0, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.cacheControl, func(t *testing.T) {
+			maxAge, stale, ok := cacheLifetime(testCase.cacheControl, testCase.authorized)
+			if maxAge != testCase.maxAge || stale != testCase.stale || ok != testCase.ok {
+				t.Errorf("cacheLifetime(%q) = %v, %v, %t; expected %v, %v, %t", testCase.cacheControl,
+					maxAge, stale, ok, testCase.maxAge, testCase.stale, testCase.ok)
+			}
+		})
+	}
+}
+
+func TestResponseCacheCoalescing(t *testing.T) {
+	type message struct {
+		Value string `json:"value"`
+	}
+
+	tests := []struct {
+		name   string
+		policy CachePolicy
+		header string
+		vary   bool
+	}{
+		{name: "private", policy: CachePolicy{MaxAge: time.Minute, Private: true}, header: "X-User"},
+		{name: "credentials", policy: CachePolicy{MaxAge: time.Minute}, header: Authorization},
+		{name: "vary", policy: CachePolicy{MaxAge: time.Minute}, header: "X-Lang", vary: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Get(func(r *Request[EmptyBody], w *Response[message]) {
+				time.Sleep(10 * time.Millisecond)
+				if tt.vary {
+					w.SetHeader(Vary, tt.header)
+				}
+				w.SetBody(&message{Value: r.Header().Get(tt.header)})
+			}, WithCachePolicy(tt.policy), WithResponseCache(NewMemoryCacheStore(10)))
+
+			// concurrent misses must not be handed a response rendered for another request
+			var wg sync.WaitGroup
+			for _, v := range []string{"a", "b", "c", "d"} {
+				wg.Add(1)
+				go func(v string) {
+					defer wg.Done()
+					r := httptest.NewRequest(http.MethodGet, "/profile", nil)
+					r.Header.Set(tt.header, v)
+					w := httptest.NewRecorder()
+					handler(w, r)
+					if expected := `{"value":"` + v + `"}`; w.Body.String() != expected {
+						t.Errorf("body = %s; expected %s", w.Body.String(), expected)
+					}
+				}(v)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestResponseCacheRevalidation(t *testing.T) {
+	var calls int32
+	store := NewMemoryCacheStore(10)
+	observer := &testObserver{}
+	handler := Get(func(r *Request[EmptyBody], w *Response[map[string]int32]) {
+		w.SetBody(&map[string]int32{"count": atomic.AddInt32(&calls, 1)})
+	}, WithCachePolicy(CachePolicy{MaxAge: time.Minute, StaleWhileRevalidate: time.Minute}), WithResponseCache(store),
+		WithObserver(observer))
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/todo", nil))
+
+	// make the cached response stale
+	store.mu.Lock()
+	for _, e := range store.entries {
+		e.Value.(*memoryCacheEntry).res.Expires = time.Now().Add(-time.Second)
+	}
+	store.mu.Unlock()
+
+	observer.mu.Lock()
+	observer.events = nil
+	observer.mu.Unlock()
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/todo", nil))
+	if w.Body.String() != `{"count":1}` {
+		t.Errorf("body = %s; expected the stale response", w.Body.String())
+	}
+
+	for i := 0; i < 100 && atomic.LoadInt32(&calls) < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("handler called %d times; expected a background revalidation", calls)
+	}
+
+	// the revalidation is not reported to the observers of the request that triggered it
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	if expected := []string{"start " + UnknownRoute, "end request"}; !reflect.DeepEqual(observer.events, expected) {
+		t.Errorf("events = %v; expected %v", observer.events, expected)
+	}
+}
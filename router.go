@@ -0,0 +1,169 @@
+package glhf
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Allow header constant.
+const Allow = "Allow"
+
+// Router registers glhf handlers by method and path on a http.ServeMux. Handlers registered for the same path
+// share one pattern, requests made with a method that has no handler are answered with 405 and an Allow header.
+// If CORS is enabled for a path, its preflight requests are answered with the methods registered for the path.
+// Options of the router and its groups apply to every handler registered with them.
+type Router struct {
+	routes  *routeTable
+	prefix  string
+	options []Options
+}
+
+// routeTable is shared by a router and its groups.
+type routeTable struct {
+	mu    sync.RWMutex
+	mux   *http.ServeMux
+	paths map[string]*route
+}
+
+// route is the set of handlers registered for a path.
+type route struct {
+	table    *routeTable
+	handlers map[string]http.Handler
+	opts     *opts
+}
+
+// NewRouter returns a Router applying options to every handler registered with it.
+func NewRouter(options ...Options) *Router {
+	return &Router{
+		routes: &routeTable{
+			mux:   http.NewServeMux(),
+			paths: make(map[string]*route),
+		},
+		options: options,
+	}
+}
+
+// Group returns a Router registering handlers under prefix with the options of rt followed by options.
+func (rt *Router) Group(prefix string, options ...Options) *Router {
+	return &Router{
+		routes:  rt.routes,
+		prefix:  rt.prefix + strings.TrimSuffix(prefix, "/"),
+		options: append(append([]Options(nil), rt.options...), options...),
+	}
+}
+
+// ServeHTTP dispatches the request to the handler registered for its path and method.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.routes.mux.ServeHTTP(w, r)
+}
+
+// HandleFunc registers a http.HandlerFunc for method and path, the router's options do not apply to it.
+func (rt *Router) HandleFunc(method string, path string, handler http.HandlerFunc) {
+	rt.routes.register(method, rt.prefix+path, handler, rt.options)
+}
+
+// Methods returns the sorted methods registered for the path, including the prefix of rt.
+// HEAD is included if GET is registered.
+func (rt *Router) Methods(path string) []string {
+	return rt.routes.methods(rt.prefix + path)
+}
+
+// Handle registers fn for method and path on rt, i.e glhf.Handle(router, http.MethodGet, "/todo/", h.LookupTodo).
+// Request bodies are treated like the matching HTTP method wrapper, i.e Get ignores the request body.
+// The handler's route defaults to its path.
+func Handle[I Body, O Body](rt *Router, method string, path string, fn HandleFunc[I, O], options ...Options) {
+	mode := BodyOptional
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		mode = BodyIgnored
+	case http.MethodPut, http.MethodPatch:
+		mode = BodyRequired
+	}
+
+	// the route is reported by its pattern unless the options name it
+	options = append(append([]Options{WithRoute(rt.prefix + path)}, rt.options...), options...)
+	rt.routes.register(method, rt.prefix+path, newHandler(method, mode, fn, options), options)
+}
+
+func (t *routeTable) register(method string, path string, handler http.Handler, options []Options) {
+	opts := defaultOptions()
+	for _, opt := range options {
+		opt.Apply(opts)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rte, ok := t.paths[path]
+	if !ok {
+		rte = &route{table: t, handlers: make(map[string]http.Handler), opts: opts}
+		t.paths[path] = rte
+		t.mux.Handle(path, rte)
+	} else if opts.cors != nil {
+		// preflights are answered for the path, any handler of the path may enable CORS
+		rte.opts.cors = opts.cors
+	}
+	rte.handlers[method] = handler
+}
+
+func (t *routeTable) methods(path string) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	rte, ok := t.paths[path]
+	if !ok {
+		return nil
+	}
+	return rte.methods()
+}
+
+func (rte *route) methods() []string {
+	methods := make([]string, 0, len(rte.handlers)+1)
+	for method := range rte.handlers {
+		methods = append(methods, method)
+	}
+	if _, ok := rte.handlers[http.MethodGet]; ok {
+		if _, ok := rte.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (rte *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rte.table.mu.RLock()
+	cors := rte.opts.cors
+	if cors != nil && isPreflight(r) {
+		methods := rte.methods()
+		rte.table.mu.RUnlock()
+		preflight(w, r, rte.opts, methods)
+		return
+	}
+
+	handler, ok := rte.handlers[r.Method]
+	// HEAD is served by GET handlers
+	if !ok && r.Method == http.MethodHead {
+		handler, ok = rte.handlers[http.MethodGet]
+	}
+	var allow []string
+	if !ok {
+		allow = rte.methods()
+	}
+	rte.table.mu.RUnlock()
+
+	if !ok {
+		if cors != nil {
+			setCORSHeaders(w, r, cors)
+		}
+		w.Header().Set(Allow, strings.Join(allow, ", "))
+		writeError(w, r, rte.opts, &errorResponse{
+			Code:    http.StatusMethodNotAllowed,
+			Message: "method " + r.Method + " is not allowed",
+		})
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,51 @@
+package glhf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	type todo struct {
+		Title string `json:"title"`
+	}
+
+	rt := NewRouter()
+	api := rt.Group("/api/")
+	Handle(api, http.MethodGet, "/todo", func(r *Request[EmptyBody], w *Response[todo]) {
+		w.SetBody(&todo{Title: "glhf"})
+	})
+	Handle(api, http.MethodDelete, "/todo", func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		w.SetStatus(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		method     string
+		path       string
+		statusCode int
+		allow      string
+	}{
+		{method: http.MethodGet, path: "/api/todo", statusCode: http.StatusOK},
+		{method: http.MethodHead, path: "/api/todo", statusCode: http.StatusOK},
+		{method: http.MethodDelete, path: "/api/todo", statusCode: http.StatusNoContent},
+		{method: http.MethodPost, path: "/api/todo", statusCode: http.StatusMethodNotAllowed, allow: "DELETE, GET, HEAD"},
+		{method: http.MethodGet, path: "/todo", statusCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+			r.Header.Set(ContentType, ContentJSON)
+			rt.ServeHTTP(w, r)
+			if w.Code != tt.statusCode {
+				t.Errorf("status = %d; expected %d", w.Code, tt.statusCode)
+			}
+			if w.Header().Get(Allow) != tt.allow {
+				t.Errorf("%s = %s; expected %s", Allow, w.Header().Get(Allow), tt.allow)
+			}
+		})
+	}
+}
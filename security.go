@@ -0,0 +1,151 @@
+package glhf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// XContentTypeOptions header constant.
+	XContentTypeOptions = "X-Content-Type-Options"
+	// ContentSecurityPolicy header constant.
+	ContentSecurityPolicy = "Content-Security-Policy"
+	// ReferrerPolicy header constant.
+	ReferrerPolicy = "Referrer-Policy"
+	// StrictTransportSecurity header constant.
+	StrictTransportSecurity = "Strict-Transport-Security"
+
+	defaultContentSecurityPolicy = "default-src 'self'; object-src 'none'; frame-ancestors 'none'; base-uri 'none'"
+	defaultReferrerPolicy        = "no-referrer"
+	defaultHSTSMaxAge            = 2 * 365 * 24 * time.Hour
+	defaultMaxJSONDepth          = 32
+)
+
+// Hardening configures security response headers and strict request decoding, see WithHardening.
+// Zero values select the defaults.
+type Hardening struct {
+	// ContentSecurityPolicy is set on HTML responses.
+	// Defaults to "default-src 'self'; object-src 'none'; frame-ancestors 'none'; base-uri 'none'".
+	ContentSecurityPolicy string
+	// ReferrerPolicy defaults to no-referrer.
+	ReferrerPolicy string
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header, set on TLS requests. Defaults to two years,
+	// a negative value disables the header.
+	HSTSMaxAge time.Duration
+	// HSTSIncludeSubdomains applies HSTS to every subdomain.
+	HSTSIncludeSubdomains bool
+	// HSTSPreload allows the domain to be included in browsers' HSTS preload lists.
+	HSTSPreload bool
+	// MaxDepth is the maximum nesting depth of objects and arrays in JSON request bodies. Defaults to 32.
+	MaxDepth int
+}
+
+func (h *Hardening) withDefaults() *Hardening {
+	c := *h
+	if len(c.ContentSecurityPolicy) == 0 {
+		c.ContentSecurityPolicy = defaultContentSecurityPolicy
+	}
+	if len(c.ReferrerPolicy) == 0 {
+		c.ReferrerPolicy = defaultReferrerPolicy
+	}
+	if c.HSTSMaxAge == 0 {
+		c.HSTSMaxAge = defaultHSTSMaxAge
+	}
+	if c.MaxDepth <= 0 {
+		c.MaxDepth = defaultMaxJSONDepth
+	}
+	return &c
+}
+
+// setSecurityHeaders sets the security headers that apply to every response.
+func setSecurityHeaders(w http.ResponseWriter, r *http.Request, h *Hardening) {
+	w.Header().Set(XContentTypeOptions, "nosniff")
+	w.Header().Set(ReferrerPolicy, h.ReferrerPolicy)
+	// browsers ignore HSTS received over plain HTTP
+	if r.TLS != nil && h.HSTSMaxAge > 0 {
+		v := "max-age=" + strconv.FormatInt(int64(h.HSTSMaxAge/time.Second), 10)
+		if h.HSTSIncludeSubdomains {
+			v += "; includeSubDomains"
+		}
+		if h.HSTSPreload {
+			v += "; preload"
+		}
+		w.Header().Set(StrictTransportSecurity, v)
+	}
+}
+
+// setContentSecurityPolicy sets the Content-Security-Policy header of HTML responses, unless the handler set one.
+func setContentSecurityPolicy(w http.ResponseWriter, h *Hardening) {
+	mt, _, _ := mime.ParseMediaType(w.Header().Get(ContentType))
+	if mt == ContentHTML && len(w.Header().Get(ContentSecurityPolicy)) == 0 {
+		w.Header().Set(ContentSecurityPolicy, h.ContentSecurityPolicy)
+	}
+}
+
+// checkJSON checks data is a single JSON value without duplicate object keys, nested at most maxDepth deep.
+func checkJSON(data []byte, maxDepth int) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := checkJSONValue(dec, maxDepth); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+// checkJSONValue reads the next value from dec, checking its nesting depth and the keys of its objects.
+func checkJSONValue(dec *json.Decoder, depth int) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+	if depth == 0 {
+		return ErrMaxDepth
+	}
+
+	switch delim {
+	case '{':
+		keys := make(map[string]struct{})
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := tok.(string)
+			if _, ok := keys[key]; ok {
+				return fmt.Errorf("%w: %q", ErrDuplicateKey, key)
+			}
+			keys[key] = struct{}{}
+			if err := checkJSONValue(dec, depth-1); err != nil {
+				return err
+			}
+		}
+	case '[':
+		for dec.More() {
+			if err := checkJSONValue(dec, depth-1); err != nil {
+				return err
+			}
+		}
+	}
+	// closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+// isHygieneError reports whether err was returned because the request body violates strict decoding rules.
+func isHygieneError(err error) bool {
+	return errors.Is(err, ErrDuplicateKey) || errors.Is(err, ErrUnknownField) ||
+		errors.Is(err, ErrTrailingData) || errors.Is(err, ErrMaxDepth) || errors.Is(err, ErrPatchOperation)
+}
@@ -0,0 +1,118 @@
+package glhf
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHardeningJSON(t *testing.T) {
+	type item struct {
+		Name  string `json:"name"`
+		Items []any  `json:"items"`
+	}
+
+	tests := []struct {
+		name      string
+		mediaType string
+		policy    DecodePolicy
+		body      string
+		err       error
+	}{
+		{name: "valid", body: `{"name":"glhf","items":[1,{"a":1}]}`},
+		{name: "duplicate key", body: `{"name":"a","name":"b"}`, err: ErrDuplicateKey},
+		{name: "nested duplicate key", body: `{"items":[{"a":1,"a":2}]}`, err: ErrDuplicateKey},
+		{name: "unknown field", body: `{"name":"a","admin":true}`, err: ErrUnknownField},
+		{name: "discard policy", policy: DecodePolicy{UnknownFields: UnknownFieldsDiscard}, body: `{"name":"a","admin":true}`},
+		{name: "trailing value", body: `{"name":"a"}{"name":"b"}`, err: ErrTrailingData},
+		{name: "trailing garbage", body: `{"name":"a"} x`, err: ErrTrailingData},
+		{name: "max depth", body: `{"items":[[[1]]]}`, err: ErrMaxDepth},
+		{name: "merge patch", mediaType: ContentMergePatch, body: `{"name":"glhf"}`},
+		{name: "merge patch duplicate key", mediaType: ContentMergePatch, body: `{"name":"a","name":"b"}`, err: ErrDuplicateKey},
+		{name: "merge patch trailing value", mediaType: ContentMergePatch, body: `{"name":"a"}{"name":"b"}`, err: ErrTrailingData},
+		{name: "merge patch unknown field", mediaType: ContentMergePatch, body: `{"name":"a","admin":true}`, err: ErrUnknownField},
+		{name: "merge patch max depth", mediaType: ContentMergePatch, body: `{"items":[[[1]]]}`, err: ErrMaxDepth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := defaultOptions()
+			WithHardening(Hardening{MaxDepth: 3}).Apply(opts)
+			WithDecodePolicy(tt.policy).Apply(opts)
+
+			mediaType := tt.mediaType
+			if len(mediaType) == 0 {
+				mediaType = ContentJSON
+			}
+			_, codec, _ := lookupCodec(mediaType)
+
+			var v item
+			err := unmarshalBody(mediaType, codec, []byte(tt.body), &v, opts)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v; expected %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestHardening(t *testing.T) {
+	type todo struct {
+		Name string `json:"name"`
+	}
+
+	handler := Post(func(r *Request[todo], w *Response[todo]) {
+		w.SetBody(r.Body())
+	}, WithHardening(Hardening{HSTSIncludeSubdomains: true}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"a"}`))
+	r.Header.Set(ContentType, ContentJSON)
+	r.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; expected %d", w.Code, http.StatusOK)
+	}
+
+	expected := map[string]string{
+		XContentTypeOptions:     "nosniff",
+		ReferrerPolicy:          defaultReferrerPolicy,
+		StrictTransportSecurity: "max-age=63072000; includeSubDomains",
+		ContentSecurityPolicy:   "",
+	}
+	for k, v := range expected {
+		if w.Header().Get(k) != v {
+			t.Errorf("%s = %q; expected %q", k, w.Header().Get(k), v)
+		}
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"a","name":"b"}`))
+	r.Header.Set(ContentType, ContentJSON)
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("duplicate key status = %d; expected %d", w.Code, http.StatusBadRequest)
+	}
+	if len(w.Header().Get(StrictTransportSecurity)) > 0 {
+		t.Error("HSTS must not be sent over plain HTTP")
+	}
+}
+
+func TestHardeningHTML(t *testing.T) {
+	handler := Get(func(r *Request[EmptyBody], w *Response[string]) {
+		page := "<p>glhf</p>"
+		w.SetBody(&page)
+		w.SetMarshalFunc(func(s string) ([]byte, error) {
+			return []byte(s), nil
+		})
+		w.SetHeader(ContentType, ContentHTML)
+	}, WithHardening(Hardening{}))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get(ContentSecurityPolicy) != defaultContentSecurityPolicy {
+		t.Errorf("%s = %q; expected %q", ContentSecurityPolicy, w.Header().Get(ContentSecurityPolicy), defaultContentSecurityPolicy)
+	}
+}
@@ -0,0 +1,617 @@
+package glhf
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureInput header constant, RFC 9421.
+	SignatureInput = "Signature-Input"
+	// Signature header constant, RFC 9421.
+	Signature = "Signature"
+
+	defaultSignatureLabel = "sig1"
+	// defaultSignatureMaxAge bounds how long a captured signature can be replayed.
+	defaultSignatureMaxAge = 5 * time.Minute
+	// signatureClockSkew is how far in the future a created parameter is accepted, signatures created later could be
+	// replayed for longer than their max age.
+	signatureClockSkew = time.Minute
+)
+
+// requiredRequestComponents are covered by every request signature, so a signature can not be replayed with another
+// method or target.
+var requiredRequestComponents = []string{"@method", "@target-uri"}
+
+// Signature algorithms of the HTTP Signature Algorithms registry, RFC 9421.
+const (
+	AlgHMACSHA256        = "hmac-sha256"
+	AlgEd25519           = "ed25519"
+	AlgECDSAP256SHA256   = "ecdsa-p256-sha256"
+	AlgECDSAP384SHA384   = "ecdsa-p384-sha384"
+	AlgRSAPSSSHA512      = "rsa-pss-sha512"
+	AlgRSAPKCS1v15SHA256 = "rsa-v1_5-sha256"
+)
+
+// jwsAlgorithms maps signature algorithms to the equivalent JWS algorithm verified by verifySignature.
+var jwsAlgorithms = map[string]string{
+	AlgHMACSHA256:        "HS256",
+	AlgEd25519:           "EdDSA",
+	AlgECDSAP256SHA256:   "ES256",
+	AlgECDSAP384SHA384:   "ES384",
+	AlgRSAPSSSHA512:      "PS512",
+	AlgRSAPKCS1v15SHA256: "RS256",
+}
+
+// SignatureKey is a local key that signs or verifies HTTP message signatures.
+type SignatureKey struct {
+	// KeyID identifies the key, it is sent as the keyid signature parameter.
+	KeyID string
+	// Algorithm is the signature algorithm, i.e AlgEd25519.
+	Algorithm string
+	// Key is the []byte secret of hmac-sha256. Otherwise it is an ed25519.PrivateKey, *ecdsa.PrivateKey or
+	// *rsa.PrivateKey to sign, or a private or public key to verify.
+	Key any
+}
+
+// SignatureConfig configures signing HTTP messages, RFC 9421.
+type SignatureConfig struct {
+	// Key signs the message.
+	Key SignatureKey
+	// Label names the signature. Defaults to sig1.
+	Label string
+	// Components are the covered components, i.e "@method", "@target-uri", "@status" or lowercase header names.
+	// Requests default to "@method", "@target-uri", "content-type" and "content-digest", responses to "@status",
+	// "content-type" and "content-digest", headers are only covered by default if present. A Content-Digest header
+	// is added if "content-digest" is covered and the message has none.
+	Components []string
+	// Expires sets the expires signature parameter to the time of signing plus Expires.
+	Expires time.Duration
+	// Tag sets the tag signature parameter, i.e the application the signature is intended for.
+	Tag string
+}
+
+// VerifyConfig configures verifying HTTP message signatures, RFC 9421.
+type VerifyConfig struct {
+	// Keys are the keys trusted to sign messages, selected by the keyid signature parameter.
+	Keys []SignatureKey
+	// Label selects the signature to verify. Defaults to the first signature.
+	Label string
+	// Required are components the signature must cover. Messages with a body must always cover content-digest and
+	// requests must always cover @method and @target-uri.
+	Required []string
+	// MaxAge rejects signatures created longer ago, and signatures without a created parameter. Defaults to five
+	// minutes, a negative value accepts signatures of any age. Signatures created more than a minute in the future are
+	// always rejected.
+	MaxAge time.Duration
+	// Tag selects the signature with this tag parameter.
+	Tag string
+}
+
+type signatureKeyIDKey struct{}
+
+// ContextSignatureKeyID returns the key ID of the verified message signature stored in ctx, or an empty string
+// if signatures are not verified.
+func ContextSignatureKeyID(ctx context.Context) string {
+	keyID, _ := ctx.Value(signatureKeyIDKey{}).(string)
+	return keyID
+}
+
+// httpMessage is the request or response a signature covers.
+type httpMessage struct {
+	request *http.Request
+	status  int
+	header  http.Header
+}
+
+// component returns the value of a covered component.
+func (m *httpMessage) component(name string) (string, error) {
+	if !strings.HasPrefix(name, "@") {
+		if len(m.header.Values(name)) == 0 {
+			return "", fmt.Errorf("%w: %s", ErrMissingComponent, name)
+		}
+		values := make([]string, 0, len(m.header.Values(name)))
+		for _, v := range m.header.Values(name) {
+			values = append(values, strings.TrimSpace(v))
+		}
+		return strings.Join(values, ", "), nil
+	}
+
+	r := m.request
+	if name == "@status" {
+		if r != nil {
+			return "", fmt.Errorf("%w: %s", ErrMissingComponent, name)
+		}
+		return strconv.Itoa(m.status), nil
+	}
+	if r == nil {
+		return "", fmt.Errorf("%w: %s", ErrMissingComponent, name)
+	}
+
+	switch name {
+	case "@method":
+		return r.Method, nil
+	case "@target-uri":
+		return requestScheme(r) + "://" + requestAuthority(r) + r.URL.RequestURI(), nil
+	case "@authority":
+		return requestAuthority(r), nil
+	case "@scheme":
+		return requestScheme(r), nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	case "@path":
+		if p := r.URL.EscapedPath(); len(p) > 0 {
+			return p, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrMissingComponent, name)
+}
+
+// requestScheme returns the scheme of a client request, or of a server request based on its connection.
+func requestScheme(r *http.Request) string {
+	if r.URL.IsAbs() {
+		return strings.ToLower(r.URL.Scheme)
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func requestAuthority(r *http.Request) string {
+	if len(r.Host) > 0 {
+		return strings.ToLower(r.Host)
+	}
+	return strings.ToLower(r.URL.Host)
+}
+
+// signatureBase returns the signature base of m covering components, params is the serialized @signature-params.
+func signatureBase(m *httpMessage, components []string, params string) (string, error) {
+	var sb strings.Builder
+	for _, c := range components {
+		v, err := m.component(c)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(`"` + c + `": ` + v + "\n")
+	}
+	sb.WriteString(`"@signature-params": ` + params)
+	return sb.String(), nil
+}
+
+// signMessage adds a signature of m, with body, to its Signature-Input and Signature headers.
+func signMessage(m *httpMessage, body []byte, config SignatureConfig, now time.Time) error {
+	label := config.Label
+	if len(label) == 0 {
+		label = defaultSignatureLabel
+	}
+
+	components := append([]string(nil), config.Components...)
+	if len(components) == 0 {
+		if m.request != nil {
+			components = []string{"@method", "@target-uri"}
+		} else {
+			components = []string{"@status"}
+		}
+		if len(m.header.Get(ContentType)) > 0 {
+			components = append(components, "content-type")
+		}
+		if len(body) > 0 {
+			components = append(components, "content-digest")
+		}
+	}
+	quoted := make([]string, len(components))
+	for i, c := range components {
+		components[i] = strings.ToLower(c)
+		quoted[i] = `"` + components[i] + `"`
+		if components[i] == "content-digest" && len(m.header.Get(ContentDigest)) == 0 {
+			m.header.Set(ContentDigest, contentDigest(body, []DigestAlgorithm{DigestSHA256}))
+		}
+	}
+
+	params := "(" + strings.Join(quoted, " ") + ");created=" + strconv.FormatInt(now.Unix(), 10)
+	if config.Expires > 0 {
+		params += ";expires=" + strconv.FormatInt(now.Add(config.Expires).Unix(), 10)
+	}
+	params += `;keyid="` + config.Key.KeyID + `";alg="` + config.Key.Algorithm + `"`
+	if len(config.Tag) > 0 {
+		params += `;tag="` + config.Tag + `"`
+	}
+
+	base, err := signatureBase(m, components, params)
+	if err != nil {
+		return err
+	}
+	sig, err := signBytes(config.Key, []byte(base))
+	if err != nil {
+		return err
+	}
+	m.header.Add(SignatureInput, label+"="+params)
+	m.header.Add(Signature, label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// signBytes signs data with key.
+func signBytes(key SignatureKey, data []byte) ([]byte, error) {
+	switch key.Algorithm {
+	case AlgHMACSHA256:
+		if secret, ok := key.Key.([]byte); ok {
+			mac := hmac.New(crypto.SHA256.New, secret)
+			mac.Write(data)
+			return mac.Sum(nil), nil
+		}
+	case AlgEd25519:
+		if k, ok := key.Key.(ed25519.PrivateKey); ok {
+			return ed25519.Sign(k, data), nil
+		}
+	case AlgECDSAP256SHA256, AlgECDSAP384SHA384:
+		hash, size := crypto.SHA256, 32
+		if key.Algorithm == AlgECDSAP384SHA384 {
+			hash, size = crypto.SHA384, 48
+		}
+		if k, ok := key.Key.(*ecdsa.PrivateKey); ok && k.Curve.Params().BitSize == size*8 {
+			h := hash.New()
+			h.Write(data)
+			r, s, err := ecdsa.Sign(rand.Reader, k, h.Sum(nil))
+			if err != nil {
+				return nil, err
+			}
+			// r and s are concatenated as fixed size big-endian integers
+			sig := make([]byte, 2*size)
+			r.FillBytes(sig[:size])
+			s.FillBytes(sig[size:])
+			return sig, nil
+		}
+	case AlgRSAPSSSHA512:
+		if k, ok := key.Key.(*rsa.PrivateKey); ok {
+			h := crypto.SHA512.New()
+			h.Write(data)
+			return rsa.SignPSS(rand.Reader, k, crypto.SHA512, h.Sum(nil), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case AlgRSAPKCS1v15SHA256:
+		if k, ok := key.Key.(*rsa.PrivateKey); ok {
+			h := crypto.SHA256.New()
+			h.Write(data)
+			return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, h.Sum(nil))
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, key.Algorithm)
+	}
+	return nil, fmt.Errorf("%w: %s key of %s", ErrInvalidKey, key.Algorithm, key.KeyID)
+}
+
+// publicKey returns the verification key of a signing key.
+func publicKey(key any) any {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return k.Public()
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	}
+	return key
+}
+
+// verifyMessage verifies a signature of m, with body, and returns the key ID of the signing key.
+func verifyMessage(m *httpMessage, body []byte, config VerifyConfig, now time.Time) (string, error) {
+	inputs := parseDictionary(strings.Join(m.header.Values(SignatureInput), ", "))
+	signatures := parseDictionary(strings.Join(m.header.Values(Signature), ", "))
+
+	var label, params string
+	for _, input := range inputs {
+		_, p, ok := parseSignatureParams(input.value)
+		if !ok || (len(config.Label) > 0 && input.key != config.Label) || (len(config.Tag) > 0 && p["tag"] != config.Tag) {
+			continue
+		}
+		label, params = input.key, input.value
+		break
+	}
+	if len(label) == 0 {
+		return "", ErrMissingSignature
+	}
+
+	components, p, _ := parseSignatureParams(params)
+	required := config.Required
+	if m.request != nil {
+		required = append(required[:len(required):len(required)], requiredRequestComponents...)
+	}
+	for _, c := range required {
+		if !containsString(components, strings.ToLower(c)) {
+			return "", fmt.Errorf("%w: %s is not covered", ErrMissingComponent, c)
+		}
+	}
+	if len(body) > 0 && !containsString(components, "content-digest") {
+		return "", fmt.Errorf("%w: content-digest is not covered", ErrMissingComponent)
+	}
+
+	if expires, ok := p["expires"]; ok {
+		if t, err := strconv.ParseInt(expires, 10, 64); err != nil || now.Unix() > t {
+			return "", ErrSignatureExpired
+		}
+	}
+	maxAge := config.MaxAge
+	if maxAge == 0 {
+		maxAge = defaultSignatureMaxAge
+	}
+	if _, ok := p["created"]; ok || maxAge > 0 {
+		created, err := strconv.ParseInt(p["created"], 10, 64)
+		if err != nil || (maxAge > 0 && now.Sub(time.Unix(created, 0)) > maxAge) {
+			return "", ErrSignatureExpired
+		}
+		if time.Unix(created, 0).Sub(now) > signatureClockSkew {
+			return "", fmt.Errorf("%w: created in the future", ErrSignatureExpired)
+		}
+	}
+
+	var key *SignatureKey
+	for i := range config.Keys {
+		if config.Keys[i].KeyID == p["keyid"] {
+			key = &config.Keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKeyID, p["keyid"])
+	}
+	if alg, ok := p["alg"]; ok && alg != key.Algorithm {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+	}
+
+	var sig []byte
+	for _, s := range signatures {
+		if s.key == label {
+			sig, _ = parseByteSequence(s.value)
+		}
+	}
+	base, err := signatureBase(m, components, params)
+	if err != nil {
+		return "", err
+	}
+	if len(sig) == 0 || !verifySignature(jwsAlgorithms[key.Algorithm], publicKey(key.Key), []byte(base), sig) {
+		return "", ErrMessageSignature
+	}
+
+	if containsString(components, "content-digest") {
+		if err := verifyContentDigest(m.header.Get(ContentDigest), body); err != nil {
+			return "", err
+		}
+	}
+	return key.KeyID, nil
+}
+
+// verifyContentDigest checks the digests of a Content-Digest header, at least one algorithm must be supported.
+func verifyContentDigest(header string, body []byte) error {
+	verified := false
+	for _, member := range parseDictionary(header) {
+		h, ok := DigestAlgorithm(member.key).hash()
+		if !ok {
+			continue
+		}
+		h.Write(body)
+		expected, ok := parseByteSequence(member.value)
+		if !ok || !hmac.Equal(h.Sum(nil), expected) {
+			return ErrContentDigest
+		}
+		verified = true
+	}
+	if !verified {
+		return ErrContentDigest
+	}
+	return nil
+}
+
+// dictionaryMember is a member of a structured field dictionary, RFC 8941.
+type dictionaryMember struct {
+	key   string
+	value string
+}
+
+// parseDictionary splits a structured field dictionary into its members, values are left serialized.
+func parseDictionary(s string) []dictionaryMember {
+	var (
+		members []dictionaryMember
+		quoted  bool
+		depth   int
+		start   int
+	)
+	add := func(member string) {
+		key, value, _ := strings.Cut(strings.TrimSpace(member), "=")
+		if len(key) > 0 {
+			members = append(members, dictionaryMember{key: key, value: value})
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			add(s[start:i])
+			start = i + 1
+		}
+	}
+	add(s[start:])
+	return members
+}
+
+// parseSignatureParams parses a serialized @signature-params inner list into its components and parameters.
+func parseSignatureParams(s string) ([]string, map[string]string, bool) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, nil, false
+	}
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, nil, false
+	}
+
+	var components []string
+	for _, item := range strings.Fields(s[1:end]) {
+		// component parameters, i.e ;req or ;sf, are not supported
+		if len(item) < 2 || item[0] != '"' || item[len(item)-1] != '"' {
+			return nil, nil, false
+		}
+		components = append(components, item[1:len(item)-1])
+	}
+
+	params := make(map[string]string)
+	for _, param := range strings.Split(s[end+1:], ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		params[key] = value
+	}
+	return components, params, true
+}
+
+// parseByteSequence decodes a structured field byte sequence, :base64:.
+func parseByteSequence(s string) ([]byte, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != ':' || s[len(s)-1] != ':' {
+		return nil, false
+	}
+	b, err := base64.StdEncoding.DecodeString(s[1 : len(s)-1])
+	return b, err == nil
+}
+
+// verifyRequest verifies the signature of a server request before its body is decoded. The raw body is restored
+// for decoding. It fails with 401 if the signature is invalid and 400 if the body does not match its digest.
+func verifyRequest(r *http.Request, config VerifyConfig, maxBodySize int64) (*http.Request, *errorResponse) {
+	var body []byte
+	if r.Body != nil {
+		reader := io.Reader(r.Body)
+		if maxBodySize > 0 {
+			reader = io.LimitReader(r.Body, maxBodySize+1)
+		}
+		b, err := io.ReadAll(reader)
+		if err != nil {
+			return r, &errorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "failed to read request body",
+			}
+		}
+		if maxBodySize > 0 && int64(len(b)) > maxBodySize {
+			return r, &errorResponse{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: "request body too large",
+			}
+		}
+		body = b
+		r.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	keyID, err := verifyMessage(&httpMessage{request: r, header: r.Header}, body, config, time.Now())
+	if err != nil {
+		code := http.StatusUnauthorized
+		if errors.Is(err, ErrContentDigest) {
+			code = http.StatusBadRequest
+		}
+		return r, &errorResponse{
+			Code:    code,
+			Message: "invalid message signature: " + err.Error(),
+		}
+	}
+	return r.WithContext(context.WithValue(r.Context(), signatureKeyIDKey{}, keyID)), nil
+}
+
+// SignResponseHook signs responses with config, it is used with WithResponseHook after hooks that modify headers.
+func SignResponseHook(config SignatureConfig) ResponseHook {
+	return func(res *EncodedResponse) error {
+		return signMessage(&httpMessage{status: res.StatusCode, header: res.Header}, res.Body, config, time.Now())
+	}
+}
+
+// SignRequest signs a client request, adding the Content-Digest, Signature-Input and Signature headers.
+// The request body is read and replaced.
+func SignRequest(r *http.Request, config SignatureConfig) error {
+	var body []byte
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return err
+		}
+		body = b
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		}
+	}
+	return signMessage(&httpMessage{request: r, header: r.Header}, body, config, time.Now())
+}
+
+// VerifyResponse verifies the signature of a client response and returns the key ID of the signing key.
+// The response body is read and replaced.
+func VerifyResponse(res *http.Response, config VerifyConfig) (string, error) {
+	var body []byte
+	if res.Body != nil {
+		b, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		body = b
+		res.Body = io.NopCloser(bytes.NewReader(b))
+	}
+	return verifyMessage(&httpMessage{status: res.StatusCode, header: res.Header}, body, config, time.Now())
+}
+
+// SigningTransport is an http.RoundTripper that signs requests and, optionally, verifies response signatures.
+// glhf has no client of its own, set it as the Transport of the http.Client used by any client, typed or not.
+type SigningTransport struct {
+	// Base sends the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// Sign signs every request.
+	Sign SignatureConfig
+	// Verify verifies the signature of every response if set.
+	Verify *VerifyConfig
+}
+
+// RoundTrip signs a copy of r and sends it.
+func (t *SigningTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	r = r.Clone(r.Context())
+	if err := SignRequest(r, t.Sign); err != nil {
+		return nil, err
+	}
+	res, err := base.RoundTrip(r)
+	if err != nil || t.Verify == nil {
+		return res, err
+	}
+	if _, err := VerifyResponse(res, *t.Verify); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,191 @@
+package glhf
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignatureBase(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "https://example.com/foo?param=Value&Pet=dog", nil)
+	r.Header.Set(ContentType, ContentJSON)
+	r.Header.Set(ContentDigest, "sha-512=:abc=:")
+	r.Header.Add("X-Forwarded-For", " 192.0.2.1 ")
+	r.Header.Add("X-Forwarded-For", "198.51.100.1")
+
+	components := []string{"@method", "@authority", "@path", "@query", "content-digest", "content-type", "x-forwarded-for"}
+	params := `("@method" "@authority" "@path" "@query" "content-digest" "content-type" "x-forwarded-for");created=1618884473;keyid="test-key"`
+	base, err := signatureBase(&httpMessage{request: r, header: r.Header}, components, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `"@method": POST
+"@authority": example.com
+"@path": /foo
+"@query": ?param=Value&Pet=dog
+"content-digest": sha-512=:abc=:
+"content-type": application/json
+"x-forwarded-for": 192.0.2.1, 198.51.100.1
+"@signature-params": ` + params
+	if base != expected {
+		t.Errorf("signature base = %s; expected %s", base, expected)
+	}
+
+	if _, err := signatureBase(&httpMessage{request: r, header: r.Header}, []string{"@status"}, params); !errors.Is(err, ErrMissingComponent) {
+		t.Errorf("err = %v; expected %v", err, ErrMissingComponent)
+	}
+}
+
+func TestSignatureAlgorithms(t *testing.T) {
+	_, ed, _ := ed25519.GenerateKey(rand.Reader)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	keys := []SignatureKey{
+		{KeyID: "hmac", Algorithm: AlgHMACSHA256, Key: []byte("secret")},
+		{KeyID: "ed25519", Algorithm: AlgEd25519, Key: ed},
+		{KeyID: "p256", Algorithm: AlgECDSAP256SHA256, Key: p256},
+		{KeyID: "p384", Algorithm: AlgECDSAP384SHA384, Key: p384},
+		{KeyID: "pss", Algorithm: AlgRSAPSSSHA512, Key: rsaKey},
+		{KeyID: "rsa", Algorithm: AlgRSAPKCS1v15SHA256, Key: rsaKey},
+	}
+	// verifiers only hold public keys
+	public := make([]SignatureKey, len(keys))
+	for i, key := range keys {
+		public[i] = key
+		public[i].Key = publicKey(key.Key)
+	}
+
+	for _, key := range keys {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "https://example.com/hooks", strings.NewReader(`{"event":"created"}`))
+			r.Header.Set(ContentType, ContentJSON)
+			if err := SignRequest(r, SignatureConfig{Key: key}); err != nil {
+				t.Fatal(err)
+			}
+			m := &httpMessage{request: r, header: r.Header}
+			body := []byte(`{"event":"created"}`)
+			keyID, err := verifyMessage(m, body, VerifyConfig{Keys: public}, time.Now())
+			if err != nil || keyID != key.KeyID {
+				t.Fatalf("verify = %q, %v; expected %q", keyID, err, key.KeyID)
+			}
+
+			r.Method = http.MethodPut
+			if _, err := verifyMessage(m, body, VerifyConfig{Keys: public}, time.Now()); !errors.Is(err, ErrMessageSignature) {
+				t.Errorf("err = %v; expected %v", err, ErrMessageSignature)
+			}
+		})
+	}
+}
+
+func TestVerifyMessage(t *testing.T) {
+	key := SignatureKey{KeyID: "hmac", Algorithm: AlgHMACSHA256, Key: []byte("secret")}
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"created"}`)
+
+	tests := []struct {
+		name   string
+		sign   SignatureConfig
+		verify VerifyConfig
+		age    time.Duration
+		modify func(r *http.Request)
+		body   []byte
+		err    error
+	}{
+		{name: "valid", sign: SignatureConfig{Key: key, Tag: "webhook"}, verify: VerifyConfig{Keys: []SignatureKey{key}, Tag: "webhook", MaxAge: time.Minute}},
+		{name: "unknown key", sign: SignatureConfig{Key: SignatureKey{KeyID: "other", Algorithm: AlgHMACSHA256, Key: []byte("secret")}},
+			verify: VerifyConfig{Keys: []SignatureKey{key}}, err: ErrUnknownKeyID},
+		{name: "tampered body", sign: SignatureConfig{Key: key}, verify: VerifyConfig{Keys: []SignatureKey{key}}, body: []byte(`{"event":"deleted"}`), err: ErrContentDigest},
+		{name: "tampered header", sign: SignatureConfig{Key: key}, verify: VerifyConfig{Keys: []SignatureKey{key}},
+			modify: func(r *http.Request) { r.Header.Set(ContentType, ContentCBOR) }, err: ErrMessageSignature},
+		{name: "missing signature", sign: SignatureConfig{Key: key}, verify: VerifyConfig{Keys: []SignatureKey{key}},
+			modify: func(r *http.Request) { r.Header.Del(SignatureInput) }, err: ErrMissingSignature},
+		{name: "other tag", sign: SignatureConfig{Key: key, Tag: "other"}, verify: VerifyConfig{Keys: []SignatureKey{key}, Tag: "webhook"}, err: ErrMissingSignature},
+		{name: "body not covered", sign: SignatureConfig{Key: key, Components: []string{"@method", "@target-uri"}}, verify: VerifyConfig{Keys: []SignatureKey{key}}, err: ErrMissingComponent},
+		{name: "method not covered", sign: SignatureConfig{Key: key, Components: []string{"@target-uri", "content-digest"}}, verify: VerifyConfig{Keys: []SignatureKey{key}}, err: ErrMissingComponent},
+		{name: "target not covered", sign: SignatureConfig{Key: key, Components: []string{"@method", "content-digest"}}, verify: VerifyConfig{Keys: []SignatureKey{key}}, err: ErrMissingComponent},
+		{name: "required component", sign: SignatureConfig{Key: key}, verify: VerifyConfig{Keys: []SignatureKey{key}, Required: []string{"@authority"}}, err: ErrMissingComponent},
+		{name: "expired", sign: SignatureConfig{Key: key, Expires: time.Second}, verify: VerifyConfig{Keys: []SignatureKey{key}}, age: time.Minute, err: ErrSignatureExpired},
+		{name: "too old", sign: SignatureConfig{Key: key}, verify: VerifyConfig{Keys: []SignatureKey{key}, MaxAge: time.Second}, age: time.Minute, err: ErrSignatureExpired},
+		{name: "replayed", sign: SignatureConfig{Key: key}, verify: VerifyConfig{Keys: []SignatureKey{key}}, age: time.Hour, err: ErrSignatureExpired},
+		{name: "any age", sign: SignatureConfig{Key: key}, verify: VerifyConfig{Keys: []SignatureKey{key}, MaxAge: -1}, age: time.Hour},
+		{name: "clock skew", sign: SignatureConfig{Key: key}, verify: VerifyConfig{Keys: []SignatureKey{key}}, age: -30 * time.Second},
+		{name: "future", sign: SignatureConfig{Key: key}, verify: VerifyConfig{Keys: []SignatureKey{key}}, age: -time.Hour, err: ErrSignatureExpired},
+		{name: "future any age", sign: SignatureConfig{Key: key}, verify: VerifyConfig{Keys: []SignatureKey{key}, MaxAge: -1}, age: -time.Hour, err: ErrSignatureExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "https://example.com/hooks", nil)
+			r.Header.Set(ContentType, ContentJSON)
+			m := &httpMessage{request: r, header: r.Header}
+
+			if err := signMessage(m, body, tt.sign, now.Add(-tt.age)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.modify != nil {
+				tt.modify(r)
+			}
+			verifyBody := body
+			if tt.body != nil {
+				verifyBody = tt.body
+			}
+			if _, err := verifyMessage(m, verifyBody, tt.verify, now); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v; expected %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSignedWebhook(t *testing.T) {
+	_, clientKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, serverKey, _ := ed25519.GenerateKey(rand.Reader)
+	client := SignatureKey{KeyID: "client", Algorithm: AlgEd25519, Key: clientKey}
+	server := SignatureKey{KeyID: "server", Algorithm: AlgEd25519, Key: serverKey}
+
+	type event struct {
+		Name string `json:"name"`
+	}
+	handler := Post(func(r *Request[event], w *Response[event]) {
+		if keyID := ContextSignatureKeyID(r.Context()); keyID != "client" {
+			t.Errorf("key ID = %q; expected client", keyID)
+		}
+		w.SetBody(r.Body())
+	}, WithSignatureVerification(VerifyConfig{Keys: []SignatureKey{client}, Required: []string{"@method", "@target-uri"}}),
+		WithResponseHook(SignResponseHook(SignatureConfig{Key: server})))
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	c := &http.Client{Transport: &SigningTransport{
+		Sign:   SignatureConfig{Key: client},
+		Verify: &VerifyConfig{Keys: []SignatureKey{server}, Required: []string{"@status"}},
+	}}
+	res, err := c.Post(ts.URL+"/hooks", ContentJSON, strings.NewReader(`{"name":"created"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status = %d; expected %d", res.StatusCode, http.StatusOK)
+	}
+
+	// unsigned requests are rejected
+	res, err = http.Post(ts.URL+"/hooks", ContentJSON, strings.NewReader(`{"name":"created"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d; expected %d", res.StatusCode, http.StatusUnauthorized)
+	}
+}
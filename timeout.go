@@ -0,0 +1,70 @@
+package glhf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// statusClientClosedRequest is reported to the logger and observers when the client disconnects before the handler
+// completes. It is not an HTTP status and never written.
+const statusClientClosedRequest = 499
+
+// callHandler calls fn. If timeout is positive, fn is called with a deadline context in its own goroutine and
+// an error is returned if the deadline passes or the client disconnects before fn returns. Headers set by fn
+// are only copied to the response once it returns, a handler that returns late can not race the error response.
+// If fn is still running when callHandler returns, the returned channel is closed once it returns.
+func callHandler[I Body, O Body](fn HandleFunc[I, O], req *Request[I], res *Response[O], timeout time.Duration) (*errorResponse, <-chan struct{}) {
+	if timeout <= 0 {
+		fn(req, res)
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(req.r.Context(), timeout)
+	defer cancel()
+	req.r = req.r.WithContext(ctx)
+	res.r = req.r
+
+	w := res.w
+	rec := &headerRecorder{header: w.Header().Clone()}
+	res.w = rec
+
+	done := make(chan any, 1)
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		defer func() {
+			done <- recover()
+		}()
+		fn(req, res)
+	}()
+
+	select {
+	case p := <-done:
+		// handler panics are raised on the serving goroutine so they are handled by net/http
+		if p != nil {
+			panic(p)
+		}
+		for k := range w.Header() {
+			delete(w.Header(), k)
+		}
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		res.w = w
+		return nil, nil
+	case <-ctx.Done():
+		// the handler's response is discarded, late calls only modify its own copy
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return &errorResponse{
+				Code:    http.StatusServiceUnavailable,
+				Message: "handler timed out",
+			}, returned
+		}
+		return &errorResponse{
+			Code:    statusClientClosedRequest,
+			Message: "client closed request",
+		}, returned
+	}
+}
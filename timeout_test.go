@@ -0,0 +1,112 @@
+package glhf
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	type todo struct {
+		Title string `json:"title"`
+	}
+
+	late := make(chan struct{})
+	handler := Get(func(r *Request[EmptyBody], w *Response[todo]) {
+		if r.URL().Query().Get("slow") == "" {
+			w.SetHeader("X-Handler", "true")
+			w.SetBody(&todo{Title: "glhf"})
+			return
+		}
+		<-r.Context().Done()
+		// the response was already written, late calls must not race the writer
+		w.SetHeader("X-Handler", "true")
+		w.SetBody(&todo{Title: "late"})
+		close(late)
+	}, WithTimeout(20*time.Millisecond))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Header().Get("X-Handler") != "true" || !strings.Contains(w.Body.String(), "glhf") {
+		t.Errorf("in time response = %d %q %s", w.Code, w.Header().Get("X-Handler"), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/?slow=true", nil))
+	<-late
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d; expected %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if len(w.Header().Get("X-Handler")) > 0 || strings.Contains(w.Body.String(), "late") {
+		t.Errorf("late response was written: %v %s", w.Header(), w.Body.String())
+	}
+}
+
+func TestTimeoutClientDisconnect(t *testing.T) {
+	var buf bytes.Buffer
+	started := make(chan struct{})
+	handler := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		close(started)
+		<-r.Context().Done()
+	}, WithTimeout(time.Minute), WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if !strings.Contains(buf.String(), `"status":499`) {
+		t.Errorf("log record missing client disconnect: %s", buf.String())
+	}
+	// 499 is only reported, it is not an HTTP status
+	if w.Body.Len() > 0 || len(w.Header().Get(ContentType)) > 0 {
+		t.Errorf("response was written to a disconnected client: %v %s", w.Header(), w.Body.String())
+	}
+}
+
+func TestTimeoutMaxInFlight(t *testing.T) {
+	block := make(chan struct{})
+	returned := make(chan struct{})
+	handler := Get(func(r *Request[EmptyBody], w *Response[EmptyBody]) {
+		if r.URL().Query().Get("block") == "true" {
+			// ignores its context and outlives the timeout
+			<-block
+			close(returned)
+		}
+	}, WithTimeout(10*time.Millisecond), WithMaxInFlight(1))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/?block=true", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d; expected the handler to time out", w.Code)
+	}
+
+	// the timed out handler still runs and holds the slot
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get(RetryAfter) != "1" {
+		t.Errorf("status = %d; expected the slot to be held by the running handler", w.Code)
+	}
+
+	close(block)
+	<-returned
+	for deadline := time.Now().Add(time.Second); ; {
+		w = httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code == http.StatusOK || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d; expected the slot to be released once the handler returned", w.Code)
+	}
+}